@@ -0,0 +1,180 @@
+package authx
+
+import (
+	"errors"
+	"net/http"
+
+	"pkg/hmacx"
+	"pkg/httpx"
+	"pkg/jwtx"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	RoleUser  = "USER"
+	RoleStaff = "STAFF"
+	RoleAdmin = "ADMIN"
+	// RoleGuest is carried by booking-scoped guest tokens, see AuthenticateGuest.
+	RoleGuest = jwtx.GuestRole
+)
+
+// Permissions granted to roles on top of self-service access to one's own data.
+const (
+	PermBookingsFrontDesk = "bookings:front-desk" // check-in / check-out
+	PermBookingsManage    = "bookings:manage"     // view and cancel any booking
+	PermPaymentsManage    = "payments:manage"     // view and refund any payment
+	PermUsersManage       = "users:manage"
+)
+
+var rolePermissions = map[string]map[string]struct{}{
+	RoleStaff: {
+		PermBookingsFrontDesk: {},
+		PermBookingsManage:    {},
+		PermPaymentsManage:    {},
+	},
+	RoleAdmin: {
+		PermBookingsFrontDesk: {},
+		PermBookingsManage:    {},
+		PermPaymentsManage:    {},
+		PermUsersManage:       {},
+	},
+}
+
+const claimsKey = "claims"
+
+// Authenticate verifies the bearer token once and stores its claims in the context.
+// It also sets "user_id" and "email" for handlers that read them directly.
+func Authenticate(tm *jwtx.TokenManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, err := jwtx.ExtractToken(c.Request)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, httpx.ErrorResponse{Error: "unauthorized"})
+			return
+		}
+		claims, err := tm.VerifyToken(token)
+		if err != nil || claims.UserID == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, httpx.ErrorResponse{Error: "unauthorized"})
+			return
+		}
+		c.Set(claimsKey, claims)
+		c.Set("user_id", claims.UserID)
+		if claims.Email != "" {
+			c.Set("email", claims.Email)
+		}
+		c.Next()
+	}
+}
+
+// AuthenticateGuest verifies a guest token and checks that it is scoped to the booking in
+// the param path parameter. Guest tokens are signed with their own secret, so tm must not
+// be the manager used for account tokens.
+func AuthenticateGuest(tm *jwtx.TokenManager, param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, err := jwtx.ExtractToken(c.Request)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, httpx.ErrorResponse{Error: "unauthorized"})
+			return
+		}
+		claims, err := tm.VerifyToken(token)
+		if err != nil || claims.Role != RoleGuest || claims.BookingID == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, httpx.ErrorResponse{Error: "unauthorized"})
+			return
+		}
+		if claims.BookingID != c.Param(param) {
+			c.AbortWithStatusJSON(http.StatusForbidden, httpx.ErrorResponse{Error: "forbidden"})
+			return
+		}
+		c.Set(claimsKey, claims)
+		if claims.Email != "" {
+			c.Set("email", claims.Email)
+		}
+		c.Next()
+	}
+}
+
+// Claims returns the claims stored by Authenticate or AuthenticateGuest, or nil.
+func Claims(c *gin.Context) *jwtx.AccessClaims {
+	v, ok := c.Get(claimsKey)
+	if !ok {
+		return nil
+	}
+	claims, _ := v.(*jwtx.AccessClaims)
+	return claims
+}
+
+// HasRole reports whether the authenticated caller has one of roles.
+func HasRole(c *gin.Context, roles ...string) bool {
+	claims := Claims(c)
+	if claims == nil {
+		return false
+	}
+	for _, r := range roles {
+		if claims.Role == r {
+			return true
+		}
+	}
+	return false
+}
+
+// Can reports whether the authenticated caller's role grants perm.
+func Can(c *gin.Context, perm string) bool {
+	claims := Claims(c)
+	if claims == nil {
+		return false
+	}
+	_, ok := rolePermissions[claims.Role][perm]
+	return ok
+}
+
+// RequireRole aborts with 403 unless the caller has one of roles. Use after Authenticate.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasRole(c, roles...) {
+			c.AbortWithStatusJSON(http.StatusForbidden, httpx.ErrorResponse{Error: "forbidden"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireVerifiedEmail aborts with 403 unless the caller's token says their email is verified.
+// Use after Authenticate.
+func RequireVerifiedEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if claims := Claims(c); claims == nil || !claims.EmailVerified {
+			c.AbortWithStatusJSON(http.StatusForbidden, httpx.ErrorResponse{Error: "email not verified"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequirePermission aborts with 403 unless the caller's role grants perm. Use after Authenticate.
+func RequirePermission(perm string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !Can(c, perm) {
+			c.AbortWithStatusJSON(http.StatusForbidden, httpx.ErrorResponse{Error: "forbidden"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// Internal only admits requests signed by another service with the shared internal
+// secret and stores the caller's name as "service".
+func Internal(v *hmacx.Verifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		svc, err := v.Verify(c.Request)
+		if err != nil {
+			status := http.StatusUnauthorized
+			if errors.Is(err, hmacx.ErrBodyTooLarge) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			c.AbortWithStatusJSON(status, httpx.ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.Set("service", svc)
+		c.Next()
+	}
+}
@@ -0,0 +1,16 @@
+package httpx
+
+import "strings"
+
+// TrustedProxies parses a comma-separated list of proxy IPs or CIDRs, such as the
+// TRUSTED_PROXIES env. An empty list trusts no proxy, so X-Forwarded-For is ignored
+// and the client IP is the address of the peer.
+func TrustedProxies(raw string) []string {
+	var proxies []string
+	for _, p := range strings.Split(raw, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			proxies = append(proxies, p)
+		}
+	}
+	return proxies
+}
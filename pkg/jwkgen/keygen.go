@@ -0,0 +1,39 @@
+// This is a helper for generating JWT signing keys for the auth service.
+// Save the output as <kid>.pem inside JWT_SIGNING_KEYS_DIR.
+// CLI: go run ./jwkgen > keys/2025-01.pem          (Ed25519, EdDSA)
+//      go run ./jwkgen rsa > keys/2025-01.pem      (RSA 2048, RS256)
+
+package main
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+)
+
+func main() {
+	var (
+		key crypto.Signer
+		err error
+	)
+	if len(os.Args) > 1 && os.Args[1] == "rsa" {
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	} else {
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		panic(err)
+	}
+	if err := pem.Encode(os.Stdout, &pem.Block{Type: "PRIVATE KEY", Bytes: der}); err != nil {
+		panic(err)
+	}
+}
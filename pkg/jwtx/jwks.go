@@ -0,0 +1,193 @@
+package jwtx
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// JWK is the subset of RFC 7517 used for RSA and Ed25519 public keys.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKSet is served at /.well-known/jwks.json.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+func publicJWK(kid string, pub crypto.PublicKey) (JWK, error) {
+	enc := base64.RawURLEncoding
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA", Kid: kid, Use: "sig", Alg: "RS256",
+			N: enc.EncodeToString(k.N.Bytes()),
+			E: enc.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+		}, nil
+	case ed25519.PublicKey:
+		return JWK{Kty: "OKP", Kid: kid, Use: "sig", Alg: "EdDSA", Crv: "Ed25519", X: enc.EncodeToString(k)}, nil
+	default:
+		return JWK{}, fmt.Errorf("unsupported key type %T", pub)
+	}
+}
+
+func (j JWK) publicKey() (crypto.PublicKey, error) {
+	enc := base64.RawURLEncoding
+	switch j.Kty {
+	case "RSA":
+		n, err := enc.DecodeString(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := enc.DecodeString(j.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "OKP":
+		if j.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", j.Crv)
+		}
+		x, err := enc.DecodeString(j.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", j.Kty)
+	}
+}
+
+// JWKSCache fetches and caches a remote JWKS. Keys are refreshed after TTL, and
+// an unknown kid triggers an early refresh (at most once per MinRefresh) so a
+// rotated key is picked up without redeploying. Only one fetch runs at a time and
+// lookups of cached keys do not wait for it.
+type JWKSCache struct {
+	URL        string
+	TTL        time.Duration
+	MinRefresh time.Duration
+
+	client *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+	// refreshing is closed when the fetch in flight finishes; nil while none runs.
+	refreshing chan struct{}
+	fetchErr   error
+}
+
+func NewJWKSCache(url string) *JWKSCache {
+	return &JWKSCache{
+		URL:        url,
+		TTL:        5 * time.Minute,
+		MinRefresh: 30 * time.Second,
+		client:     &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// PublicKey implements KeySource.
+func (c *JWKSCache) PublicKey(kid string) (crypto.PublicKey, error) {
+	c.mu.Lock()
+	now := time.Now()
+	_, known := c.keys[kid]
+	stale := c.keys == nil || now.Sub(c.fetchedAt) > c.TTL
+	if !known && now.Sub(c.fetchedAt) > c.MinRefresh {
+		stale = true
+	}
+
+	var err error
+	switch {
+	case stale && c.refreshing == nil:
+		err = c.refresh(now)
+	case c.refreshing != nil && !known:
+		// the fetch in flight may bring the key
+		err = c.wait()
+	}
+	pub, ok := c.keys[kid]
+	loaded := c.keys != nil
+	c.mu.Unlock()
+
+	if !loaded {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return pub, nil
+}
+
+// refresh fetches the key set without holding c.mu, so lookups are not blocked by
+// the request, and swaps it in; on failure the previous keys are kept.
+// c.mu is held on entry and on return.
+func (c *JWKSCache) refresh(now time.Time) error {
+	done := make(chan struct{})
+	c.refreshing = done
+	c.fetchedAt = now
+	c.mu.Unlock()
+
+	keys, err := c.fetch()
+
+	c.mu.Lock()
+	if err == nil {
+		c.keys = keys
+	}
+	c.fetchErr = err
+	c.refreshing = nil
+	close(done)
+	return err
+}
+
+// wait blocks until the fetch in flight finishes and returns its error.
+// c.mu is held on entry and on return.
+func (c *JWKSCache) wait() error {
+	done := c.refreshing
+	c.mu.Unlock()
+	<-done
+	c.mu.Lock()
+	return c.fetchErr
+}
+
+func (c *JWKSCache) fetch() (map[string]crypto.PublicKey, error) {
+	resp, err := c.client.Get(c.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks returned %s", resp.Status)
+	}
+	var set JWKSet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, j := range set.Keys {
+		pub, err := j.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[j.Kid] = pub
+	}
+	return keys, nil
+}
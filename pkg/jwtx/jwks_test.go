@@ -0,0 +1,129 @@
+package jwtx
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// jwksServer serves one Ed25519 key as kid "k1". While gate is set, requests wait on it.
+type jwksServer struct {
+	*httptest.Server
+	fetches  atomic.Int32
+	arrived  chan struct{}
+	gate     chan struct{}
+	gateOnce sync.Once
+}
+
+func newJWKSServer(t *testing.T) *jwksServer {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	key, err := NewSigningKey("k1", priv)
+	if err != nil {
+		t.Fatalf("NewSigningKey() error = %v", err)
+	}
+	set := NewKeySet(key).JWKS()
+
+	s := &jwksServer{arrived: make(chan struct{}, 16)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.fetches.Add(1)
+		s.arrived <- struct{}{}
+		if s.gate != nil {
+			<-s.gate
+		}
+		_ = json.NewEncoder(w).Encode(set)
+	}))
+	t.Cleanup(func() {
+		s.open()
+		s.Close()
+	})
+	return s
+}
+
+func (s *jwksServer) open() {
+	if s.gate != nil {
+		s.gateOnce.Do(func() { close(s.gate) })
+	}
+}
+
+func TestJWKSCacheFetchesOnceForConcurrentLookups(t *testing.T) {
+	srv := newJWKSServer(t)
+	srv.gate = make(chan struct{})
+	cache := NewJWKSCache(srv.URL)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := cache.PublicKey("k1")
+			errs <- err
+		}()
+	}
+	<-srv.arrived
+	srv.open()
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("PublicKey() error = %v", err)
+		}
+	}
+	if n := srv.fetches.Load(); n != 1 {
+		t.Errorf("fetches = %d, want 1", n)
+	}
+}
+
+func TestJWKSCacheServesCachedKeysDuringRefresh(t *testing.T) {
+	srv := newJWKSServer(t)
+	cache := NewJWKSCache(srv.URL)
+	if _, err := cache.PublicKey("k1"); err != nil {
+		t.Fatalf("PublicKey() error = %v", err)
+	}
+	<-srv.arrived
+
+	// expire the cache and hold the refresh open
+	srv.gate = make(chan struct{})
+	cache.mu.Lock()
+	cache.fetchedAt = time.Now().Add(-2 * cache.TTL)
+	cache.mu.Unlock()
+	refreshed := make(chan error, 1)
+	go func() {
+		_, err := cache.PublicKey("k1")
+		refreshed <- err
+	}()
+	<-srv.arrived
+
+	got := make(chan error, 1)
+	go func() {
+		_, err := cache.PublicKey("k1")
+		got <- err
+	}()
+	select {
+	case err := <-got:
+		if err != nil {
+			t.Errorf("PublicKey() during refresh error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("PublicKey() of a cached key waited for the refresh")
+	}
+
+	srv.open()
+	if err := <-refreshed; err != nil {
+		t.Errorf("refreshing PublicKey() error = %v", err)
+	}
+	if n := srv.fetches.Load(); n != 2 {
+		t.Errorf("fetches = %d, want 2", n)
+	}
+}
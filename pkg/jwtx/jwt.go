@@ -1,6 +1,8 @@
 package jwtx
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"net/http"
 	"strings"
@@ -12,39 +14,122 @@ import (
 var (
 	ErrMissingBearer = errors.New("missing token")
 	ErrInvalidToken  = errors.New("mnvalid token")
+	ErrRevokedToken  = errors.New("token revoked")
 )
 
+// RevocationChecker reports whether a token ID (jti) has been revoked, and up to
+// when every access token of a user is revoked (zero if none is).
+type RevocationChecker interface {
+	IsRevoked(jti string) bool
+	RevokedBefore(userID string) time.Time
+}
+
+// Option customizes a TokenManager.
+type Option func(*TokenManager)
+
+// WithSigningKey makes SignToken use an asymmetric key and set the kid header.
+func WithSigningKey(key *SigningKey) Option {
+	return func(m *TokenManager) { m.signingKey = key }
+}
+
+// WithKeySource makes VerifyToken accept only asymmetric tokens whose kid resolves
+// through ks; HS256 tokens are rejected so verifiers cannot mint tokens.
+func WithKeySource(ks KeySource) Option {
+	return func(m *TokenManager) { m.keys = ks }
+}
+
+// WithRevocationChecker makes VerifyToken reject tokens whose jti is revoked or that
+// were issued before their user's tokens were revoked.
+func WithRevocationChecker(rc RevocationChecker) Option {
+	return func(m *TokenManager) { m.revocations = rc }
+}
+
+// DefaultAccessTTL is the lifetime of access tokens when not configured.
+const DefaultAccessTTL = 15 * time.Minute
+
+// GuestRole is the role of booking-scoped guest tokens.
+const GuestRole = "GUEST"
+
 type TokenManager struct {
-	Secret []byte
-	Issuer string
+	Secret    []byte
+	Issuer    string
+	AccessTTL time.Duration
+
+	signingKey  *SigningKey
+	keys        KeySource
+	revocations RevocationChecker
 }
 
 type AccessClaims struct {
-	UserID string `json:"user_id"`
-	Email  string `json:"email"`
-	Role   string `json:"role"`
+	UserID        string `json:"user_id"`
+	Email         string `json:"email"`
+	Role          string `json:"role"`
+	EmailVerified bool   `json:"email_verified"`
+	// BookingID scopes a guest token to a single booking; it is empty for account tokens.
+	BookingID string `json:"booking_id,omitempty"`
+	// AuthTime is when the user last signed in; tokens from a refresh keep the original time.
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func New(secret, issuer string) *TokenManager {
-	return &TokenManager{
-		Secret: []byte(secret),
-		Issuer: issuer,
+func New(secret, issuer string, opts ...Option) *TokenManager {
+	m := &TokenManager{
+		Secret:    []byte(secret),
+		Issuer:    issuer,
+		AccessTTL: DefaultAccessTTL,
 	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
 }
 
-func (m *TokenManager) SignToken(userID, email, role string) (string, error) {
-	now := time.Now().UTC()
+// SignToken issues an account token. authTime is when the user signed in and may be
+// zero when unknown, in which case the token carries no auth_time.
+func (m *TokenManager) SignToken(userID, email, role string, emailVerified bool, authTime time.Time) (string, error) {
 	claims := AccessClaims{
-		UserID: userID,
-		Email:  email,
-		Role:   role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			Issuer:   m.Issuer,
-			Subject:  userID,
-			IssuedAt: jwt.NewNumericDate(now),
-		},
+		UserID:        userID,
+		Email:         email,
+		Role:          role,
+		EmailVerified: emailVerified,
+	}
+	if !authTime.IsZero() {
+		claims.AuthTime = jwt.NewNumericDate(authTime)
+	}
+	return m.sign(claims, userID)
+}
+
+// SignGuestToken issues a token for someone without an account that only grants
+// access to one booking. Guest tokens carry no user ID, so Authenticate rejects them.
+func (m *TokenManager) SignGuestToken(bookingID, email string) (string, error) {
+	return m.sign(AccessClaims{
+		Email:     email,
+		Role:      GuestRole,
+		BookingID: bookingID,
+	}, "booking:"+bookingID)
+}
+
+func (m *TokenManager) sign(claims AccessClaims, subject string) (string, error) {
+	jti, err := newJTI()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now().UTC()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		Issuer:   m.Issuer,
+		Subject:  subject,
+		ID:       jti,
+		IssuedAt: jwt.NewNumericDate(now),
 	}
+	if m.AccessTTL > 0 {
+		claims.ExpiresAt = jwt.NewNumericDate(now.Add(m.AccessTTL))
+	}
+	if m.signingKey != nil {
+		t := jwt.NewWithClaims(m.signingKey.method, claims)
+		t.Header["kid"] = m.signingKey.KID
+		return t.SignedString(m.signingKey.Key)
+	}
+
 	t := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
 	return t.SignedString(m.Secret)
@@ -52,19 +137,54 @@ func (m *TokenManager) SignToken(userID, email, role string) (string, error) {
 
 func (m *TokenManager) VerifyToken(token string) (*AccessClaims, error) {
 	claims := new(AccessClaims)
-	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
+	_, err := jwt.ParseWithClaims(token, claims, m.keyFunc)
+
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if m.revocations != nil && claims.ID != "" && m.revocations.IsRevoked(claims.ID) {
+		return nil, ErrRevokedToken
+	}
+	if m.revocations != nil && claims.UserID != "" {
+		// iat has second precision, so tokens issued in the second of the revocation are rejected too
+		before := m.revocations.RevokedBefore(claims.UserID)
+		if !before.IsZero() && (claims.IssuedAt == nil || !claims.IssuedAt.After(before)) {
+			return nil, ErrRevokedToken
+		}
+	}
+
+	return claims, nil
+}
+
+func (m *TokenManager) keyFunc(t *jwt.Token) (any, error) {
+	if m.keys == nil {
 		if t.Method != jwt.SigningMethodHS256 {
 			return nil, ErrInvalidToken
 		}
-
 		return m.Secret, nil
-	})
+	}
 
+	kid, _ := t.Header["kid"].(string)
+	if kid == "" {
+		return nil, ErrInvalidToken
+	}
+	pub, err := m.keys.PublicKey(kid)
 	if err != nil {
+		return nil, err
+	}
+	if want := methodFor(pub); want == nil || t.Method.Alg() != want.Alg() {
 		return nil, ErrInvalidToken
 	}
+	return pub, nil
+}
 
-	return claims, nil
+func newJTI() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
 }
 
 func ExtractToken(r *http.Request) (string, error) {
@@ -0,0 +1,139 @@
+package jwtx
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// KeySource resolves the public key for a token's kid header.
+type KeySource interface {
+	PublicKey(kid string) (crypto.PublicKey, error)
+}
+
+// SigningKey is an asymmetric private key identified by kid.
+type SigningKey struct {
+	KID    string
+	Key    crypto.Signer
+	method jwt.SigningMethod
+}
+
+// NewSigningKey wraps an RSA (RS256) or Ed25519 (EdDSA) private key.
+func NewSigningKey(kid string, key crypto.Signer) (*SigningKey, error) {
+	if kid == "" {
+		return nil, errors.New("signing key id is required")
+	}
+	var method jwt.SigningMethod
+	switch key.(type) {
+	case *rsa.PrivateKey:
+		method = jwt.SigningMethodRS256
+	case ed25519.PrivateKey:
+		method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+	return &SigningKey{KID: kid, Key: key, method: method}, nil
+}
+
+// ParsePrivateKeyPEM parses a PKCS#8 (or PKCS#1 RSA) PEM private key.
+func ParsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported key type %T", key)
+		}
+		return signer, nil
+	}
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
+}
+
+// LoadSigningKeys reads every <kid>.pem file in dir, sorted by kid.
+func LoadSigningKeys(dir string) ([]*SigningKey, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	keys := make([]*SigningKey, 0, len(paths))
+	for _, p := range paths {
+		raw, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := ParsePrivateKeyPEM(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		key, err := NewSigningKey(strings.TrimSuffix(filepath.Base(p), ".pem"), signer)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// KeySet is a static set of public keys, e.g. the auth service's own keys.
+type KeySet struct {
+	keys map[string]crypto.PublicKey
+	kids []string
+}
+
+// NewKeySet publishes the public halves of the given signing keys.
+func NewKeySet(keys ...*SigningKey) *KeySet {
+	ks := &KeySet{keys: make(map[string]crypto.PublicKey, len(keys))}
+	for _, k := range keys {
+		ks.keys[k.KID] = k.Key.Public()
+		ks.kids = append(ks.kids, k.KID)
+	}
+	return ks
+}
+
+// PublicKey implements KeySource.
+func (ks *KeySet) PublicKey(kid string) (crypto.PublicKey, error) {
+	pub, ok := ks.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return pub, nil
+}
+
+// JWKS returns the key set as a JSON Web Key Set document.
+func (ks *KeySet) JWKS() JWKSet {
+	set := JWKSet{Keys: make([]JWK, 0, len(ks.kids))}
+	for _, kid := range ks.kids {
+		if jwk, err := publicJWK(kid, ks.keys[kid]); err == nil {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
+}
+
+// methodFor reports the signing method expected for a public key.
+func methodFor(pub crypto.PublicKey) jwt.SigningMethod {
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		return jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA
+	default:
+		return nil
+	}
+}
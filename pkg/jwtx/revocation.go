@@ -0,0 +1,155 @@
+package jwtx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"pkg/hmacx"
+)
+
+// RevokedToken is one entry of the auth service revocation list.
+type RevokedToken struct {
+	JTI       string    `json:"jti"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// RevokedUser revokes every access token of a user issued up to RevokedAt, e.g.
+// after the account was disabled or its role changed.
+type RevokedUser struct {
+	UserID    string    `json:"user_id"`
+	RevokedAt time.Time `json:"revoked_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type revocationResponse struct {
+	Data struct {
+		AsOf    time.Time      `json:"as_of"`
+		Revoked []RevokedToken `json:"revoked"`
+		Users   []RevokedUser  `json:"users"`
+	} `json:"data"`
+}
+
+// RevocationCache keeps a local copy of revoked JTIs and users, refreshed from the
+// auth service's internal revocation endpoint. Entries are dropped once the tokens
+// they cover would have expired. If auth is unreachable the last known list is kept.
+type RevocationCache struct {
+	url    string
+	client *http.Client
+	signer *hmacx.Signer
+
+	mu      sync.RWMutex
+	revoked map[string]time.Time
+	users   map[string]RevokedUser
+	since   time.Time
+}
+
+// revocationOverlap is how far each refresh re-reads before the previous as_of. A
+// revocation is stamped before its transaction commits, so one committing just after a
+// listing would otherwise fall behind the cursor; entries read twice are de-duplicated.
+const revocationOverlap = 10 * time.Second
+
+// NewRevocationCache builds a cache that polls GET {authBase}/internal/revocations.
+func NewRevocationCache(authBase string, signer *hmacx.Signer) *RevocationCache {
+	return &RevocationCache{
+		url:     authBase + "/internal/revocations",
+		client:  &http.Client{Timeout: 5 * time.Second},
+		signer:  signer,
+		revoked: make(map[string]time.Time),
+		users:   make(map[string]RevokedUser),
+	}
+}
+
+// IsRevoked implements RevocationChecker.
+func (c *RevocationCache) IsRevoked(jti string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, ok := c.revoked[jti]
+	return ok
+}
+
+// RevokedBefore implements RevocationChecker.
+func (c *RevocationCache) RevokedBefore(userID string) time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.users[userID].RevokedAt
+}
+
+// Refresh fetches revocations added since the previous refresh.
+func (c *RevocationCache) Refresh(ctx context.Context) error {
+	c.mu.RLock()
+	since := c.since
+	c.mu.RUnlock()
+
+	q := url.Values{}
+	if !since.IsZero() {
+		q.Set("since", since.Add(-revocationOverlap).Format(time.RFC3339Nano))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url+"?"+q.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	if err := c.signer.Sign(req, nil); err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("revocation list returned %s", resp.Status)
+	}
+	var out revocationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, r := range out.Data.Revoked {
+		c.revoked[r.JTI] = r.ExpiresAt
+	}
+	for _, u := range out.Data.Users {
+		if u.RevokedAt.After(c.users[u.UserID].RevokedAt) {
+			c.users[u.UserID] = u
+		}
+	}
+	for jti, exp := range c.revoked {
+		if now.After(exp) {
+			delete(c.revoked, jti)
+		}
+	}
+	for id, u := range c.users {
+		if now.After(u.ExpiresAt) {
+			delete(c.users, id)
+		}
+	}
+	c.since = out.Data.AsOf
+	return nil
+}
+
+// Run refreshes the cache immediately and then every interval until ctx is cancelled.
+func (c *RevocationCache) Run(ctx context.Context, interval time.Duration) {
+	if err := c.Refresh(ctx); err != nil {
+		log.Printf("revocation cache: %v", err)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.Refresh(ctx); err != nil {
+				log.Printf("revocation cache: %v", err)
+			}
+		}
+	}
+}
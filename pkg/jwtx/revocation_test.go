@@ -0,0 +1,52 @@
+package jwtx
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"pkg/hmacx"
+)
+
+func TestRevocationCacheOverlapsCursor(t *testing.T) {
+	asOf := time.Now().UTC().Truncate(time.Second).Add(400 * time.Millisecond)
+	var sinces []string
+	var respond []RevokedToken
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sinces = append(sinces, r.URL.Query().Get("since"))
+		var out revocationResponse
+		out.Data.AsOf = asOf
+		out.Data.Revoked = respond
+		_ = json.NewEncoder(w).Encode(out)
+	}))
+	defer srv.Close()
+	cache := NewRevocationCache(srv.URL, hmacx.NewSigner("test", "secret"))
+	expires := time.Now().Add(time.Hour)
+
+	respond = []RevokedToken{{JTI: "a", ExpiresAt: expires}}
+	if err := cache.Refresh(context.Background()); err != nil {
+		t.Fatalf("first Refresh() error = %v", err)
+	}
+	// "b" was stamped before the first as_of but committed after that listing
+	respond = []RevokedToken{{JTI: "a", ExpiresAt: expires}, {JTI: "b", ExpiresAt: expires}}
+	if err := cache.Refresh(context.Background()); err != nil {
+		t.Fatalf("second Refresh() error = %v", err)
+	}
+
+	if sinces[0] != "" {
+		t.Errorf("first since = %q, want none", sinces[0])
+	}
+	since, err := time.Parse(time.RFC3339Nano, sinces[1])
+	if err != nil {
+		t.Fatalf("second since %q: %v", sinces[1], err)
+	}
+	if want := asOf.Add(-revocationOverlap); !since.Equal(want) {
+		t.Errorf("second since = %s, want %s", since, want)
+	}
+	if !cache.IsRevoked("a") || !cache.IsRevoked("b") || len(cache.revoked) != 2 {
+		t.Errorf("revoked = %v, want a and b once", cache.revoked)
+	}
+}
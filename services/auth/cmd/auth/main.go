@@ -4,12 +4,17 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"auth/internal/entity"
 	"auth/internal/handler"
+	"auth/internal/notify"
+	"auth/internal/oidc"
 	"auth/internal/repo"
 	"auth/internal/service"
 	"pkg/dbx"
+	"pkg/hmacx"
+	"pkg/httpx"
 	"pkg/jwtx"
 
 	"github.com/gin-gonic/gin"
@@ -26,27 +31,124 @@ func main() {
 		log.Fatalf("init database: %v", err)
 	}
 
-	if err := db.AutoMigrate(&entity.User{}); err != nil {
+	if err := db.AutoMigrate(&entity.User{}, &entity.RefreshToken{}, &entity.RevokedToken{}, &entity.UserRevocation{}, &entity.PasswordResetToken{}, &entity.EmailVerificationToken{}, &entity.LoginAttempt{}, &entity.RecoveryCode{}, &entity.LoginChallenge{}, &entity.Identity{}, &entity.OIDCLoginState{}); err != nil {
 		log.Fatalf("auto migrate: %v", err)
 	}
 
 	jwtSecret := os.Getenv("JWT_SECRET")
+	keysDir := os.Getenv("JWT_SIGNING_KEYS_DIR")
 
-	if jwtSecret == "" {
-		log.Fatal("JWT_SECRET env is required")
+	if jwtSecret == "" && keysDir == "" {
+		log.Fatal("JWT_SECRET or JWT_SIGNING_KEYS_DIR env is required")
 	}
 
-	tokenManager := jwtx.New(jwtSecret, "auth-service")
+	// With signing keys, tokens are signed with the active key and every key in the
+	// directory is published in the JWKS so tokens from a rotated-out key stay valid.
+	keySet := jwtx.NewKeySet()
+	var tokenOpts []jwtx.Option
+	if keysDir != "" {
+		keys, err := jwtx.LoadSigningKeys(keysDir)
+		if err != nil {
+			log.Fatalf("load signing keys: %v", err)
+		}
+		if len(keys) == 0 {
+			log.Fatalf("no *.pem signing keys in %s", keysDir)
+		}
+		active := keys[len(keys)-1]
+		if kid := os.Getenv("JWT_ACTIVE_KID"); kid != "" {
+			active = nil
+			for _, k := range keys {
+				if k.KID == kid {
+					active = k
+				}
+			}
+			if active == nil {
+				log.Fatalf("JWT_ACTIVE_KID %q not found in %s", kid, keysDir)
+			}
+		}
+		keySet = jwtx.NewKeySet(keys...)
+		tokenOpts = append(tokenOpts, jwtx.WithSigningKey(active), jwtx.WithKeySource(keySet))
+		log.Printf("signing tokens with key %s", active.KID)
+	}
+
+	revocationRepo := repo.NewRevocationRepository(db)
+	tokenOpts = append(tokenOpts, jwtx.WithRevocationChecker(revocationRepo))
+	tokenManager := jwtx.New(jwtSecret, "auth-service", tokenOpts...)
+	if ttl := durationEnv("ACCESS_TOKEN_TTL"); ttl > 0 {
+		tokenManager.AccessTTL = ttl
+	}
+	refreshTTL := durationEnv("REFRESH_TOKEN_TTL")
+
 	userRepo := repo.NewUserRepository(db)
-	authUsecase := service.NewAuthService(userRepo, tokenManager)
-	handler := handler.NewAuthHandler(authUsecase)
+	refreshRepo := repo.NewRefreshTokenRepository(db)
+	throttle := service.NewLoginThrottle(repo.NewLoginAttemptRepository(db), service.DefaultAccountThrottle, service.DefaultIPThrottle)
+	totpIssuer := os.Getenv("TOTP_ISSUER")
+	if totpIssuer == "" {
+		totpIssuer = "Go Hotel Book"
+	}
+	twoFactorUsecase := service.NewTwoFactorService(userRepo, repo.NewRecoveryCodeRepository(db), repo.NewLoginChallengeRepository(db), throttle, totpIssuer)
+	authUsecase := service.NewAuthService(userRepo, refreshRepo, revocationRepo, tokenManager, throttle, twoFactorUsecase, refreshTTL)
+
+	internalSecret := os.Getenv("INTERNAL_API_SECRET")
+	if internalSecret == "" {
+		log.Fatal("INTERNAL_API_SECRET env is required")
+	}
+	adminUsecase := service.NewUserAdminService(userRepo, refreshRepo, revocationRepo, tokenManager.AccessTTL)
+
+	// NOTIFY_LOG_FILE collects outgoing emails locally; without it they are only logged
+	notifier := notify.NewLogNotifier(os.Getenv("NOTIFY_LOG_FILE"))
+	resetRepo := repo.NewPasswordResetRepository(db)
+	resetUsecase := service.NewPasswordResetService(userRepo, resetRepo, refreshRepo, notifier, durationEnv("PASSWORD_RESET_TTL"))
+	verificationRepo := repo.NewEmailVerificationRepository(db)
+	verifyUsecase := service.NewEmailVerificationService(userRepo, verificationRepo, notifier, durationEnv("EMAIL_VERIFICATION_TTL"))
+	profileUsecase := service.NewProfileService(userRepo, refreshRepo)
+
+	// personal data exports pull the user's bookings and payments from their services
+	signer := hmacx.NewSigner("auth", internalSecret)
+	bookingBase := os.Getenv("BOOKING_BASE_URL")
+	if bookingBase == "" {
+		bookingBase = "http://booking:8003"
+	}
+	paymentBase := os.Getenv("PAYMENT_BASE_URL")
+	if paymentBase == "" {
+		paymentBase = "http://payment:8004"
+	}
+	identityRepo := repo.NewIdentityRepository(db)
+	accountUsecase := service.NewAccountService(userRepo, refreshRepo, revocationRepo, identityRepo, repo.NewRecoveryCodeRepository(db), resetRepo, verificationRepo,
+		repo.NewUserDataHTTPClient(bookingBase, "/internal/bookings", signer),
+		repo.NewUserDataHTTPClient(paymentBase, "/internal/payments", signer),
+		tokenManager.AccessTTL)
+
+	handler := handler.NewAuthHandler(authUsecase, adminUsecase, resetUsecase, verifyUsecase, twoFactorUsecase, profileUsecase, accountUsecase, tokenManager, hmacx.NewVerifier(internalSecret))
+
+	// OIDC login is enabled by configuring an identity provider
+	if issuer := os.Getenv("OIDC_ISSUER_URL"); issuer != "" {
+		cfg := oidc.Config{
+			IssuerURL:    issuer,
+			ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+			RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+		}
+		if cfg.ClientID == "" || cfg.RedirectURL == "" {
+			log.Fatal("OIDC_CLIENT_ID and OIDC_REDIRECT_URL env are required with OIDC_ISSUER_URL")
+		}
+		handler.SetOIDC(service.NewOIDCService(oidc.NewProvider(cfg), userRepo, identityRepo, repo.NewOIDCStateRepository(db)))
+	}
 
 	r := gin.Default()
+	// gin trusts every proxy by default, which lets clients spoof their IP
+	if err := r.SetTrustedProxies(httpx.TrustedProxies(os.Getenv("TRUSTED_PROXIES"))); err != nil {
+		log.Fatalf("invalid TRUSTED_PROXIES: %v", err)
+	}
 
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "ok", "service": "auth"})
 	})
 
+	r.GET("/.well-known/jwks.json", func(c *gin.Context) {
+		c.JSON(http.StatusOK, keySet.JWKS())
+	})
+
 	handler.BindRoutes(r)
 
 	log.Printf("auth service listening on :%s", port)
@@ -54,3 +156,16 @@ func main() {
 		log.Fatalf("server exited: %v", err)
 	}
 }
+
+// durationEnv parses a Go duration env var; it returns 0 when unset.
+func durationEnv(key string) time.Duration {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 {
+		log.Fatalf("invalid %s: %q", key, raw)
+	}
+	return d
+}
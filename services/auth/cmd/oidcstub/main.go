@@ -0,0 +1,40 @@
+// This is a stand-in OpenID provider for trying the OIDC login locally.
+// It approves every login at once, for the email in ?login_hint= or booker@example.com.
+// CLI: go run ./cmd/oidcstub -> then start auth with
+//   OIDC_ISSUER_URL=http://localhost:9000 OIDC_CLIENT_ID=hotel-local
+//   OIDC_REDIRECT_URL=http://localhost:8001/api/v1/auth/oidc/callback
+
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+
+	"auth/internal/oidc"
+)
+
+func main() {
+	issuer := os.Getenv("OIDC_STUB_ISSUER")
+	if issuer == "" {
+		issuer = "http://localhost:9000"
+	}
+	clientID := os.Getenv("OIDC_STUB_CLIENT_ID")
+	if clientID == "" {
+		clientID = "hotel-local"
+	}
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "9000"
+	}
+
+	stub, err := oidc.NewStubProvider(issuer, clientID)
+	if err != nil {
+		log.Fatalf("start oidc stub: %v", err)
+	}
+
+	log.Printf("oidc stub %s (client_id %s) listening on :%s", issuer, clientID, port)
+	if err := http.ListenAndServe(":"+port, stub); err != nil {
+		log.Fatalf("server exited: %v", err)
+	}
+}
@@ -8,13 +8,23 @@ import (
 )
 
 type User struct {
-	ID             uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
-	FullName       string    `gorm:"size:150;not null" json:"full_name"`
-	Email          string    `gorm:"size:150;uniqueIndex;not null" json:"email"`
-	HashedPassword string    `gorm:"size:255;not null" json:"-"`
-	Role           string    `gorm:"size:50;not null" json:"role"`
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
+	ID              uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	FullName        string     `gorm:"size:150;not null" json:"full_name"`
+	Email           string     `gorm:"size:150;uniqueIndex;not null" json:"email"`
+	HashedPassword  string     `gorm:"size:255;not null" json:"-"`
+	Role            string     `gorm:"size:50;not null" json:"role"`
+	Disabled        bool       `gorm:"not null;default:false" json:"disabled"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	// TOTPSecret is set on enrollment; 2FA is only enforced once TOTPEnabledAt is set.
+	// TOTPLastStep is the last accepted time step, so a code cannot be replayed.
+	TOTPSecret    string     `gorm:"column:totp_secret;size:64" json:"-"`
+	TOTPEnabledAt *time.Time `gorm:"column:totp_enabled_at" json:"-"`
+	TOTPLastStep  int64      `gorm:"column:totp_last_step;not null;default:0" json:"-"`
+	// AnonymizedAt is set when the user deleted their account; the row is kept so
+	// bookings and payments retained for accounting still reference a user.
+	AnonymizedAt *time.Time `json:"anonymized_at,omitempty"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
 }
 
 func (u *User) BeforeCreate(_ *gorm.DB) error {
@@ -23,3 +33,164 @@ func (u *User) BeforeCreate(_ *gorm.DB) error {
 	}
 	return nil
 }
+
+// IsEmailVerified reports whether the user confirmed their email address.
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// IsTwoFactorEnabled reports whether logins need a TOTP or recovery code.
+func (u *User) IsTwoFactorEnabled() bool {
+	return u.TOTPEnabledAt != nil
+}
+
+// RefreshToken is a persisted, opaque refresh session. Only the SHA-256 hash of the
+// token is stored. Tokens rotated from the same login share a FamilyID so that reuse
+// of an already-rotated token can revoke the whole chain.
+type RefreshToken struct {
+	ID           uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID       uuid.UUID  `gorm:"type:uuid;index;not null" json:"user_id"`
+	FamilyID     uuid.UUID  `gorm:"type:uuid;index;not null" json:"family_id"`
+	TokenHash    string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	ExpiresAt    time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	ReplacedByID *uuid.UUID `gorm:"type:uuid" json:"replaced_by_id"`
+	// AuthenticatedAt is when the login that started the family happened.
+	AuthenticatedAt *time.Time `json:"authenticated_at"`
+	CreatedAt       time.Time
+}
+
+func (t *RefreshToken) BeforeCreate(_ *gorm.DB) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	if t.FamilyID == uuid.Nil {
+		t.FamilyID = t.ID
+	}
+	return nil
+}
+
+// RevokedToken blocks an access token by its jti until the token would have expired.
+type RevokedToken struct {
+	JTI       string    `gorm:"size:64;primaryKey" json:"jti"`
+	UserID    uuid.UUID `gorm:"type:uuid;index" json:"user_id"`
+	ExpiresAt time.Time `gorm:"index;not null" json:"expires_at"`
+	RevokedAt time.Time `gorm:"index;not null" json:"revoked_at"`
+}
+
+// UserRevocation blocks every access token of a user issued up to RevokedAt, e.g.
+// after the account was disabled or its role changed. It is kept until those tokens
+// would have expired; a later revocation of the same user replaces it.
+type UserRevocation struct {
+	UserID    uuid.UUID `gorm:"type:uuid;primaryKey" json:"user_id"`
+	RevokedAt time.Time `gorm:"index;not null" json:"revoked_at"`
+	ExpiresAt time.Time `gorm:"index;not null" json:"expires_at"`
+}
+
+// PasswordResetToken is a single-use token mailed to a user to set a new password.
+// Only the SHA-256 hash of the token is stored.
+type PasswordResetToken struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;index;not null" json:"user_id"`
+	TokenHash string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time
+}
+
+func (t *PasswordResetToken) BeforeCreate(_ *gorm.DB) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
+
+// EmailVerificationToken is a single-use token mailed to a user to confirm they own
+// their email address. Only the SHA-256 hash of the token is stored.
+type EmailVerificationToken struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;index;not null" json:"user_id"`
+	TokenHash string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time
+}
+
+func (t *EmailVerificationToken) BeforeCreate(_ *gorm.DB) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
+
+// LoginAttempt counts consecutive failed logins for one throttling key, such as an
+// email address or a client IP, and the time until which further logins are refused.
+type LoginAttempt struct {
+	Key           string     `gorm:"size:200;primaryKey" json:"key"`
+	Failures      int        `gorm:"not null;default:0" json:"failures"`
+	LastFailureAt time.Time  `gorm:"not null" json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until"`
+}
+
+// RecoveryCode is a single-use code that can replace a TOTP code when the
+// authenticator is lost. Only the SHA-256 hash of the code is stored.
+type RecoveryCode struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;index;not null" json:"user_id"`
+	CodeHash  string     `gorm:"size:64;not null" json:"-"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time
+}
+
+func (c *RecoveryCode) BeforeCreate(_ *gorm.DB) error {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	return nil
+}
+
+// LoginChallenge is issued after a correct password for users with 2FA and is
+// exchanged, together with a valid code, for the real tokens.
+type LoginChallenge struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;index;not null" json:"user_id"`
+	TokenHash string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	Attempts  int        `gorm:"not null;default:0" json:"attempts"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time
+}
+
+func (c *LoginChallenge) BeforeCreate(_ *gorm.DB) error {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	return nil
+}
+
+// Identity links a user to their account at an external OpenID provider.
+type Identity struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID    uuid.UUID `gorm:"type:uuid;index;not null" json:"user_id"`
+	Issuer    string    `gorm:"size:255;not null;uniqueIndex:idx_identity_issuer_subject" json:"issuer"`
+	Subject   string    `gorm:"size:255;not null;uniqueIndex:idx_identity_issuer_subject" json:"subject"`
+	Email     string    `gorm:"size:150" json:"email"`
+	CreatedAt time.Time
+}
+
+func (i *Identity) BeforeCreate(_ *gorm.DB) error {
+	if i.ID == uuid.Nil {
+		i.ID = uuid.New()
+	}
+	return nil
+}
+
+// OIDCLoginState remembers an OIDC login between the redirect to the provider and
+// the callback. It is looked up by the hash of the state parameter and used once.
+type OIDCLoginState struct {
+	StateHash    string    `gorm:"size:64;primaryKey" json:"-"`
+	Nonce        string    `gorm:"size:64;not null" json:"-"`
+	CodeVerifier string    `gorm:"size:64;not null" json:"-"`
+	ExpiresAt    time.Time `gorm:"index;not null" json:"expires_at"`
+	CreatedAt    time.Time
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"pkg/authx"
+	"pkg/httpx"
+
+	"github.com/gin-gonic/gin"
+)
+
+type deleteAccountRequest struct {
+	// Password may be omitted by accounts linked to an OpenID provider that signed in recently.
+	Password string `json:"password"`
+}
+
+// HandleExportAccount serves the caller's personal data as a downloadable JSON archive.
+func (h *AuthHandler) HandleExportAccount(c *gin.Context) {
+	claims := authx.Claims(c)
+	export, err := h.account.Export(c.Request.Context(), claims.UserID)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="account-%s.json"`, claims.UserID))
+	c.JSON(http.StatusOK, export)
+}
+
+// HandleDeleteAccount anonymizes the caller's account; the service revokes all of its tokens.
+func (h *AuthHandler) HandleDeleteAccount(c *gin.Context) {
+	var req deleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	claims := authx.Claims(c)
+	var authTime time.Time
+	if claims.AuthTime != nil {
+		authTime = claims.AuthTime.Time
+	}
+	if err := h.account.Delete(c.Request.Context(), claims.UserID, req.Password, authTime); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"auth/internal/service"
+
+	"pkg/authx"
+	"pkg/httpx"
+
+	"github.com/gin-gonic/gin"
+)
+
+type createUserRequest struct {
+	FullName string `json:"full_name" binding:"required,min=3"`
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required,min=6"`
+	Role     string `json:"role" binding:"required"`
+}
+
+type changeRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+func (h *AuthHandler) HandleAdminCreateUser(c *gin.Context) {
+	var req createUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	user, err := h.admin.CreateUser(c.Request.Context(), service.CreateUserInput{
+		FullName: req.FullName,
+		Email:    req.Email,
+		Password: req.Password,
+		Role:     req.Role,
+	})
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, httpx.OK(user))
+}
+
+func (h *AuthHandler) HandleAdminListUsers(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	offset, _ := strconv.Atoi(c.Query("offset"))
+
+	list, err := h.admin.ListUsers(c.Request.Context(), service.ListUsersInput{
+		Query:  c.Query("q"),
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, httpx.OK(list))
+}
+
+func (h *AuthHandler) HandleAdminDisableUser(c *gin.Context) {
+	h.setDisabled(c, true)
+}
+
+func (h *AuthHandler) HandleAdminEnableUser(c *gin.Context) {
+	h.setDisabled(c, false)
+}
+
+func (h *AuthHandler) setDisabled(c *gin.Context, disabled bool) {
+	user, err := h.admin.SetDisabled(c.Request.Context(), authx.Claims(c).UserID, c.Param("id"), disabled)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, httpx.OK(user))
+}
+
+func (h *AuthHandler) HandleAdminChangeRole(c *gin.Context) {
+	var req changeRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	user, err := h.admin.ChangeRole(c.Request.Context(), authx.Claims(c).UserID, c.Param("id"), req.Role)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, httpx.OK(user))
+}
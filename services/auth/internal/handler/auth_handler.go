@@ -2,21 +2,36 @@ package handler
 
 import (
 	"errors"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"auth/internal/service"
 
+	"pkg/hmacx"
 	"pkg/httpx"
+	"pkg/jwtx"
 
 	"github.com/gin-gonic/gin"
 )
 
 type AuthHandler struct {
-	svc service.AuthService
+	svc       service.AuthService
+	admin     service.UserAdminService
+	resets    service.PasswordResetService
+	verify    service.EmailVerificationService
+	twoFactor service.TwoFactorService
+	profile   service.ProfileService
+	account   service.AccountService
+	oidc      service.OIDCService
+	tm        *jwtx.TokenManager
+	internal  *hmacx.Verifier
 }
 
-func NewAuthHandler(svc service.AuthService) *AuthHandler {
-	return &AuthHandler{svc: svc}
+func NewAuthHandler(svc service.AuthService, admin service.UserAdminService, resets service.PasswordResetService, verify service.EmailVerificationService, twoFactor service.TwoFactorService, profile service.ProfileService, account service.AccountService, tm *jwtx.TokenManager, internal *hmacx.Verifier) *AuthHandler {
+	return &AuthHandler{svc: svc, admin: admin, resets: resets, verify: verify, twoFactor: twoFactor, profile: profile, account: account, tm: tm, internal: internal}
 }
 
 type registerRequest struct {
@@ -30,6 +45,10 @@ type loginRequest struct {
 	Password string `json:"password" binding:"required,min=6"`
 }
 
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
 func (h *AuthHandler) HandleRegister(c *gin.Context) {
 	var req registerRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -49,6 +68,11 @@ func (h *AuthHandler) HandleRegister(c *gin.Context) {
 		return
 	}
 
+	// the account exists either way; a failed mail can be retried via the resend endpoint
+	if err := h.verify.SendVerification(c.Request.Context(), result.User.ID); err != nil {
+		log.Printf("send email verification to user %s: %v", result.User.ID, err)
+	}
+
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Account created successfully",
 		"data":    result,
@@ -62,9 +86,12 @@ func (h *AuthHandler) HandleLogin(c *gin.Context) {
 		return
 	}
 
+	// ClientIP only reads X-Forwarded-For from TRUSTED_PROXIES; without any it is
+	// c.RemoteIP(), so a client cannot dodge the per-IP throttle with a forged header.
 	result, err := h.svc.Login(c.Request.Context(), service.LoginInput{
 		Email:    req.Email,
 		Password: req.Password,
+		ClientIP: c.ClientIP(),
 	})
 
 	if err != nil {
@@ -75,12 +102,127 @@ func (h *AuthHandler) HandleLogin(c *gin.Context) {
 	c.JSON(http.StatusOK, httpx.OK(result))
 }
 
+func (h *AuthHandler) HandleRefresh(c *gin.Context) {
+	var req refreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	result, err := h.svc.Refresh(c.Request.Context(), req.RefreshToken)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, httpx.OK(result))
+}
+
+func (h *AuthHandler) HandleLogout(c *gin.Context) {
+	var req refreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	// the bearer token is optional; when present it is revoked as well
+	accessToken, _ := jwtx.ExtractToken(c.Request)
+	if err := h.svc.Logout(c.Request.Context(), req.RefreshToken, accessToken); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// HandleRevocations serves revoked access token IDs to other services. since is an
+// RFC 3339 time with sub-second precision, normally the as_of of the previous response.
+func (h *AuthHandler) HandleRevocations(c *gin.Context) {
+	var since time.Time
+	if raw := c.Query("since"); raw != "" {
+		parsed, err := parseSince(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, httpx.ErrorResponse{Error: "invalid since"})
+			return
+		}
+		since = parsed
+	}
+
+	result, err := h.svc.Revocations(c.Request.Context(), since)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, httpx.OK(result))
+}
+
+// parseSince reads an RFC 3339 time, or unix seconds as sent by services not yet updated.
+func parseSince(raw string) (time.Time, error) {
+	if unix, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.Unix(unix, 0).UTC(), nil
+	}
+	return time.Parse(time.RFC3339Nano, raw)
+}
+
+type lookupUsersRequest struct {
+	IDs []string `json:"ids" binding:"required,min=1,max=100"`
+}
+
+// HandleLookupUsers lets other services resolve user IDs to names and emails.
+func (h *AuthHandler) HandleLookupUsers(c *gin.Context) {
+	var req lookupUsersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	users, err := h.svc.LookupUsers(c.Request.Context(), req.IDs)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, httpx.OK(gin.H{"users": users}))
+}
+
 func handleError(c *gin.Context, err error) {
+	var lockout *service.LockoutError
 	switch {
-	case errors.Is(err, service.ErrEmailAlreadyUsed):
+	case errors.As(err, &lockout):
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(lockout.RetryAfter.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, httpx.ErrorResponse{Error: service.ErrTooManyAttempts.Error()})
+	case errors.Is(err, service.ErrEmailAlreadyUsed),
+		errors.Is(err, service.ErrInvalidRole),
+		errors.Is(err, service.ErrInvalidUserInput),
+		errors.Is(err, service.ErrInvalidResetToken),
+		errors.Is(err, service.ErrInvalidVerificationToken),
+		errors.Is(err, service.ErrTwoFactorNotEnrolled),
+		errors.Is(err, service.ErrTwoFactorNotEnabled),
+		errors.Is(err, service.ErrIncorrectPassword),
+		errors.Is(err, service.ErrInvalidOIDCState),
+		errors.Is(err, service.ErrOIDCEmailRequired):
 		c.JSON(http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
-	case errors.Is(err, service.ErrInvalidCredentials):
+	case errors.Is(err, service.ErrAccountDisabled),
+		errors.Is(err, service.ErrCannotModifySelf):
+		c.JSON(http.StatusForbidden, httpx.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, service.ErrTwoFactorAlreadyEnabled),
+		errors.Is(err, service.ErrOIDCEmailUnverified),
+		errors.Is(err, service.ErrActiveBookings),
+		errors.Is(err, service.ErrAccountDeleted):
+		c.JSON(http.StatusConflict, httpx.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, service.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, httpx.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, service.ErrInvalidCredentials),
+		errors.Is(err, service.ErrInvalidRefresh),
+		errors.Is(err, service.ErrRefreshReused),
+		errors.Is(err, service.ErrInvalidTwoFactorCode),
+		errors.Is(err, service.ErrInvalidChallenge),
+		errors.Is(err, service.ErrReauthenticationRequired),
+		errors.Is(err, service.ErrOIDCLoginFailed):
 		c.JSON(http.StatusUnauthorized, httpx.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, service.ErrAccountDataUnavailable):
+		c.JSON(http.StatusServiceUnavailable, httpx.ErrorResponse{Error: service.ErrAccountDataUnavailable.Error()})
 	default:
 		c.JSON(http.StatusInternalServerError, httpx.ErrorResponse{Error: "internal server error"})
 	}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"net/http"
+
+	"pkg/httpx"
+
+	"github.com/gin-gonic/gin"
+)
+
+type verifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type resendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+func (h *AuthHandler) HandleVerifyEmail(c *gin.Context) {
+	var req verifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := h.verify.Verify(c.Request.Context(), req.Token); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Email verified; refresh your session to update the access token",
+	})
+}
+
+// HandleResendVerification always answers 202 so callers cannot probe which emails are registered.
+func (h *AuthHandler) HandleResendVerification(c *gin.Context) {
+	var req resendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := h.verify.Resend(c.Request.Context(), req.Email); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "If the email is registered and not yet verified, a verification token has been sent",
+	})
+}
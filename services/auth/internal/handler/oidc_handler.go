@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"auth/internal/service"
+
+	"pkg/httpx"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetOIDC enables the OpenID Connect login routes. It must be called before BindRoutes.
+func (h *AuthHandler) SetOIDC(svc service.OIDCService) {
+	h.oidc = svc
+}
+
+// oidcStateCookie binds an OIDC login to the browser that started it, so a
+// callback URL planted in another browser is rejected (login CSRF).
+const oidcStateCookie = "oidc_state"
+
+// HandleOIDCLogin redirects the browser to the identity provider.
+func (h *AuthHandler) HandleOIDCLogin(c *gin.Context) {
+	target, state, err := h.oidc.Start(c.Request.Context())
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	setOIDCStateCookie(c, state, int(service.OIDCStateTTL.Seconds()))
+	c.Redirect(http.StatusFound, target)
+}
+
+// HandleOIDCCallback is the redirect URL registered at the identity provider. It
+// answers like HandleLogin, including the 2FA challenge for enrolled users.
+func (h *AuthHandler) HandleOIDCCallback(c *gin.Context) {
+	if providerErr := c.Query("error"); providerErr != "" {
+		c.JSON(http.StatusUnauthorized, httpx.ErrorResponse{Error: "identity provider: " + providerErr})
+		return
+	}
+	code, state := c.Query("code"), c.Query("state")
+	if code == "" || state == "" {
+		c.JSON(http.StatusBadRequest, httpx.ErrorResponse{Error: "code and state are required"})
+		return
+	}
+	bound, _ := c.Cookie(oidcStateCookie)
+	setOIDCStateCookie(c, "", -1)
+	if bound == "" || subtle.ConstantTimeCompare([]byte(bound), []byte(state)) != 1 {
+		handleError(c, service.ErrInvalidOIDCState)
+		return
+	}
+
+	user, err := h.oidc.Callback(c.Request.Context(), code, state)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	result, err := h.svc.CompleteLogin(c.Request.Context(), user)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, httpx.OK(result))
+}
+
+// setOIDCStateCookie scopes the cookie to the OIDC routes; SameSite=Lax still
+// sends it on the provider's top-level redirect back to the callback.
+func setOIDCStateCookie(c *gin.Context, state string, maxAge int) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/api/v1/auth/oidc",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+}
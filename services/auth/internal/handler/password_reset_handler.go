@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"net/http"
+
+	"pkg/httpx"
+
+	"github.com/gin-gonic/gin"
+)
+
+type passwordResetRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type passwordResetConfirmRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+// HandleRequestPasswordReset always answers 202 so callers cannot probe which emails are registered.
+func (h *AuthHandler) HandleRequestPasswordReset(c *gin.Context) {
+	var req passwordResetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := h.resets.RequestReset(c.Request.Context(), req.Email); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "If the email is registered, a reset token has been sent",
+	})
+}
+
+func (h *AuthHandler) HandleConfirmPasswordReset(c *gin.Context) {
+	var req passwordResetConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := h.resets.ConfirmReset(c.Request.Context(), req.Token, req.Password); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
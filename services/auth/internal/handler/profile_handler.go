@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"net/http"
+
+	"auth/internal/service"
+
+	"pkg/authx"
+	"pkg/httpx"
+
+	"github.com/gin-gonic/gin"
+)
+
+type updateProfileRequest struct {
+	FullName string `json:"full_name" binding:"required,min=3"`
+}
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=6"`
+}
+
+func (h *AuthHandler) HandleGetMe(c *gin.Context) {
+	profile, err := h.profile.Get(c.Request.Context(), authx.Claims(c).UserID)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, httpx.OK(profile))
+}
+
+func (h *AuthHandler) HandleUpdateMe(c *gin.Context) {
+	var req updateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	profile, err := h.profile.Update(c.Request.Context(), authx.Claims(c).UserID, service.UpdateProfileInput{
+		FullName: req.FullName,
+	})
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, httpx.OK(profile))
+}
+
+func (h *AuthHandler) HandleChangePassword(c *gin.Context) {
+	var req changePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := h.profile.ChangePassword(c.Request.Context(), authx.Claims(c).UserID, req.CurrentPassword, req.NewPassword); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -1,9 +1,52 @@
 package handler
 
-import "github.com/gin-gonic/gin"
+import (
+	"pkg/authx"
+
+	"github.com/gin-gonic/gin"
+)
 
 func (h *AuthHandler) BindRoutes(r *gin.Engine) {
 	g := r.Group("/api/v1/auth")
 	g.POST("/register", h.HandleRegister)
 	g.POST("/login", h.HandleLogin)
+	g.POST("/login/2fa", h.HandleLoginTwoFactor)
+	g.POST("/refresh", h.HandleRefresh)
+	g.POST("/logout", h.HandleLogout)
+	g.POST("/password-reset/request", h.HandleRequestPasswordReset)
+	g.POST("/password-reset/confirm", h.HandleConfirmPasswordReset)
+	g.POST("/verify-email", h.HandleVerifyEmail)
+	g.POST("/verify-email/resend", h.HandleResendVerification)
+
+	if h.oidc != nil {
+		g.GET("/oidc/login", h.HandleOIDCLogin)
+		g.GET("/oidc/callback", h.HandleOIDCCallback)
+	}
+
+	me := g.Group("/me", authx.Authenticate(h.tm))
+	me.GET("", h.HandleGetMe)
+	me.PATCH("", h.HandleUpdateMe)
+	me.DELETE("", h.HandleDeleteAccount)
+	me.GET("/export", h.HandleExportAccount)
+	me.POST("/password", h.HandleChangePassword)
+
+	// TOTP is offered to accounts that can act on other people's bookings and payments
+	twoFactor := g.Group("/2fa", authx.Authenticate(h.tm), authx.RequireRole(authx.RoleStaff, authx.RoleAdmin))
+	twoFactor.POST("/enroll", h.HandleTwoFactorEnroll)
+	twoFactor.POST("/activate", h.HandleTwoFactorActivate)
+	twoFactor.POST("/recovery-codes", h.HandleTwoFactorRecoveryCodes)
+	twoFactor.POST("/disable", h.HandleTwoFactorDisable)
+
+	admin := r.Group("/api/v1/admin/users")
+	admin.Use(authx.Authenticate(h.tm), authx.RequireRole(authx.RoleAdmin))
+	admin.POST("", h.HandleAdminCreateUser)
+	admin.GET("", h.HandleAdminListUsers)
+	admin.POST("/:id/disable", h.HandleAdminDisableUser)
+	admin.POST("/:id/enable", h.HandleAdminEnableUser)
+	admin.PUT("/:id/role", h.HandleAdminChangeRole)
+
+	internal := r.Group("/internal")
+	internal.Use(authx.Internal(h.internal))
+	internal.GET("/revocations", h.HandleRevocations)
+	internal.POST("/users/lookup", h.HandleLookupUsers)
 }
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"net/http"
+
+	"pkg/authx"
+	"pkg/httpx"
+
+	"github.com/gin-gonic/gin"
+)
+
+type loginTwoFactorRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+// twoFactorCodeRequest takes a TOTP code or, except for activation, a recovery code.
+type twoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+func (h *AuthHandler) HandleLoginTwoFactor(c *gin.Context) {
+	var req loginTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	result, err := h.svc.LoginTwoFactor(c.Request.Context(), req.ChallengeToken, req.Code)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, httpx.OK(result))
+}
+
+func (h *AuthHandler) HandleTwoFactorEnroll(c *gin.Context) {
+	enrollment, err := h.twoFactor.Enroll(c.Request.Context(), authx.Claims(c).UserID)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, httpx.OK(enrollment))
+}
+
+func (h *AuthHandler) HandleTwoFactorActivate(c *gin.Context) {
+	h.withCode(c, func(code string) (any, error) {
+		return h.twoFactor.Activate(c.Request.Context(), authx.Claims(c).UserID, code)
+	})
+}
+
+func (h *AuthHandler) HandleTwoFactorRecoveryCodes(c *gin.Context) {
+	h.withCode(c, func(code string) (any, error) {
+		return h.twoFactor.RegenerateRecoveryCodes(c.Request.Context(), authx.Claims(c).UserID, code)
+	})
+}
+
+func (h *AuthHandler) HandleTwoFactorDisable(c *gin.Context) {
+	var req twoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := h.twoFactor.Disable(c.Request.Context(), authx.Claims(c).UserID, req.Code); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// withCode binds a twoFactorCodeRequest and responds with the result of fn.
+func (h *AuthHandler) withCode(c *gin.Context, fn func(code string) (any, error)) {
+	var req twoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	result, err := fn(req.Code)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, httpx.OK(result))
+}
@@ -0,0 +1,60 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Message is an outgoing notification to a user.
+type Message struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// Notifier delivers messages to users. Implementations may send email, SMS, etc.
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// logNotifier writes messages to the process log or, when a path is set, appends
+// them as JSON lines to a file. It is meant for local development.
+type logNotifier struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewLogNotifier returns a Notifier that logs messages; with a non-empty path they
+// are appended to that file instead.
+func NewLogNotifier(path string) Notifier {
+	return &logNotifier{path: path}
+}
+
+func (n *logNotifier) Send(_ context.Context, msg Message) error {
+	if n.path == "" {
+		log.Printf("notify to=%s subject=%q body=%q", msg.To, msg.Subject, msg.Body)
+		return nil
+	}
+
+	line, err := json.Marshal(struct {
+		Message
+		SentAt time.Time `json:"sent_at"`
+	}{msg, time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	f, err := os.OpenFile(n.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
@@ -0,0 +1,182 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"pkg/jwtx"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// StubProvider is a minimal in-process OpenID provider for local development and
+// tests. Every authorization request is approved at once for the user named by the
+// login_hint parameter (DefaultEmail otherwise); codes are single-use and PKCE is
+// enforced. Mount it on an httptest.Server or run it with cmd/oidcstub.
+type StubProvider struct {
+	Issuer       string
+	ClientID     string
+	DefaultEmail string
+
+	key  *rsa.PrivateKey
+	keys *jwtx.KeySet
+
+	mu    sync.Mutex
+	codes map[string]stubGrant
+}
+
+type stubGrant struct {
+	email       string
+	nonce       string
+	challenge   string
+	redirectURI string
+	expiresAt   time.Time
+}
+
+const stubKID = "oidc-stub"
+
+// NewStubProvider generates a fresh RSA key; issuer must be the URL the stub is served at.
+func NewStubProvider(issuer, clientID string) (*StubProvider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	signing, err := jwtx.NewSigningKey(stubKID, key)
+	if err != nil {
+		return nil, err
+	}
+	return &StubProvider{
+		Issuer:       strings.TrimRight(issuer, "/"),
+		ClientID:     clientID,
+		DefaultEmail: "booker@example.com",
+		key:          key,
+		keys:         jwtx.NewKeySet(signing),
+		codes:        map[string]stubGrant{},
+	}, nil
+}
+
+func (s *StubProvider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		writeJSON(w, http.StatusOK, discovery{
+			Issuer:                s.Issuer,
+			AuthorizationEndpoint: s.Issuer + "/authorize",
+			TokenEndpoint:         s.Issuer + "/token",
+			JWKSURI:               s.Issuer + "/jwks",
+		})
+	case "/jwks":
+		writeJSON(w, http.StatusOK, s.keys.JWKS())
+	case "/authorize":
+		s.authorize(w, r)
+	case "/token":
+		s.token(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *StubProvider) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	redirectURI := q.Get("redirect_uri")
+	if q.Get("response_type") != "code" || q.Get("client_id") != s.ClientID || redirectURI == "" ||
+		q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	target, err := url.Parse(redirectURI)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	email := q.Get("login_hint")
+	if email == "" {
+		email = s.DefaultEmail
+	}
+	code, err := NewCodeVerifier()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	s.mu.Lock()
+	s.codes[code] = stubGrant{
+		email:       strings.ToLower(email),
+		nonce:       q.Get("nonce"),
+		challenge:   q.Get("code_challenge"),
+		redirectURI: redirectURI,
+		expiresAt:   time.Now().Add(time.Minute),
+	}
+	s.mu.Unlock()
+
+	params := target.Query()
+	params.Set("code", code)
+	params.Set("state", q.Get("state"))
+	target.RawQuery = params.Encode()
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+func (s *StubProvider) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	code := r.PostForm.Get("code")
+	s.mu.Lock()
+	grant, ok := s.codes[code]
+	delete(s.codes, code)
+	s.mu.Unlock()
+
+	if !ok || time.Now().After(grant.expiresAt) ||
+		grant.redirectURI != r.PostForm.Get("redirect_uri") ||
+		CodeChallenge(r.PostForm.Get("code_verifier")) != grant.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := idTokenClaims{
+		Email:         grant.email,
+		EmailVerified: true,
+		Name:          strings.SplitN(grant.email, "@", 2)[0],
+		Nonce:         grant.nonce,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.Issuer,
+			Subject:   "stub|" + grant.email,
+			Audience:  jwt.ClaimStrings{s.ClientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(5 * time.Minute)),
+		},
+	}
+	t := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	t.Header["kid"] = stubKID
+	idToken, err := t.SignedString(s.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	accessToken, err := NewCodeVerifier()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,60 @@
+package repo
+
+import (
+	"auth/internal/entity"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// EmailVerificationRepository defines persistence operations for email verification tokens.
+type EmailVerificationRepository interface {
+	Create(ctx context.Context, token *entity.EmailVerificationToken) error
+	FindByHash(ctx context.Context, hash string) (*entity.EmailVerificationToken, error)
+	// MarkUsed consumes an unused token. It reports false when the token was already used.
+	MarkUsed(ctx context.Context, id uuid.UUID) (bool, error)
+	// InvalidateForUser consumes every outstanding token of a user.
+	InvalidateForUser(ctx context.Context, userID uuid.UUID) error
+}
+
+// emailVerificationRepository implements EmailVerificationRepository using GORM.
+type emailVerificationRepository struct {
+	db *gorm.DB
+}
+
+// NewEmailVerificationRepository wires a GORM-backed email verification token repository.
+func NewEmailVerificationRepository(db *gorm.DB) EmailVerificationRepository {
+	return &emailVerificationRepository{db: db}
+}
+
+func (r *emailVerificationRepository) Create(ctx context.Context, token *entity.EmailVerificationToken) error {
+	return r.db.WithContext(ctx).Create(token).Error
+}
+
+func (r *emailVerificationRepository) FindByHash(ctx context.Context, hash string) (*entity.EmailVerificationToken, error) {
+	var token entity.EmailVerificationToken
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *emailVerificationRepository) MarkUsed(ctx context.Context, id uuid.UUID) (bool, error) {
+	res := r.db.WithContext(ctx).
+		Model(&entity.EmailVerificationToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now().UTC())
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+func (r *emailVerificationRepository) InvalidateForUser(ctx context.Context, userID uuid.UUID) error {
+	return r.db.WithContext(ctx).
+		Model(&entity.EmailVerificationToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now().UTC()).Error
+}
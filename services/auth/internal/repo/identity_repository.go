@@ -0,0 +1,101 @@
+package repo
+
+import (
+	"auth/internal/entity"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// IdentityRepository defines persistence operations for external identities.
+type IdentityRepository interface {
+	Create(ctx context.Context, identity *entity.Identity) error
+	FindBySubject(ctx context.Context, issuer, subject string) (*entity.Identity, error)
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]entity.Identity, error)
+	DeleteForUser(ctx context.Context, userID uuid.UUID) error
+}
+
+// OIDCStateRepository stores in-flight OIDC logins.
+type OIDCStateRepository interface {
+	Create(ctx context.Context, state *entity.OIDCLoginState) error
+	// Consume deletes and returns the state with the given hash.
+	Consume(ctx context.Context, hash string) (*entity.OIDCLoginState, error)
+	// DeleteExpired removes states that expired before the given time.
+	DeleteExpired(ctx context.Context, before time.Time) error
+}
+
+// identityRepository implements IdentityRepository using GORM.
+type identityRepository struct {
+	db *gorm.DB
+}
+
+// NewIdentityRepository wires a GORM-backed identity repository.
+func NewIdentityRepository(db *gorm.DB) IdentityRepository {
+	return &identityRepository{db: db}
+}
+
+func (r *identityRepository) Create(ctx context.Context, identity *entity.Identity) error {
+	return r.db.WithContext(ctx).Create(identity).Error
+}
+
+func (r *identityRepository) FindBySubject(ctx context.Context, issuer, subject string) (*entity.Identity, error) {
+	var identity entity.Identity
+	if err := r.db.WithContext(ctx).Where("issuer = ? AND subject = ?", issuer, subject).First(&identity).Error; err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (r *identityRepository) ListByUser(ctx context.Context, userID uuid.UUID) ([]entity.Identity, error) {
+	var out []entity.Identity
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at ASC").Find(&out).Error; err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *identityRepository) DeleteForUser(ctx context.Context, userID uuid.UUID) error {
+	return r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&entity.Identity{}).Error
+}
+
+// oidcStateRepository implements OIDCStateRepository using GORM.
+type oidcStateRepository struct {
+	db *gorm.DB
+}
+
+// NewOIDCStateRepository wires a GORM-backed OIDC login state repository.
+func NewOIDCStateRepository(db *gorm.DB) OIDCStateRepository {
+	return &oidcStateRepository{db: db}
+}
+
+func (r *oidcStateRepository) Create(ctx context.Context, state *entity.OIDCLoginState) error {
+	return r.db.WithContext(ctx).Create(state).Error
+}
+
+func (r *oidcStateRepository) Consume(ctx context.Context, hash string) (*entity.OIDCLoginState, error) {
+	var state entity.OIDCLoginState
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("state_hash = ?", hash).First(&state).Error; err != nil {
+			return err
+		}
+		res := tx.Where("state_hash = ?", hash).Delete(&entity.OIDCLoginState{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			// consumed concurrently
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func (r *oidcStateRepository) DeleteExpired(ctx context.Context, before time.Time) error {
+	return r.db.WithContext(ctx).Where("expires_at < ?", before).Delete(&entity.OIDCLoginState{}).Error
+}
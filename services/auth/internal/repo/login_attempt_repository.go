@@ -0,0 +1,73 @@
+package repo
+
+import (
+	"auth/internal/entity"
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LoginAttemptRepository persists failed login counters used for throttling.
+type LoginAttemptRepository interface {
+	// Find returns the attempts recorded for the given keys; keys without failures are omitted.
+	Find(ctx context.Context, keys ...string) ([]entity.LoginAttempt, error)
+	// RecordFailure atomically increments the failure count of key and returns the new count.
+	// Counts whose last failure is before resetBefore start again from one.
+	RecordFailure(ctx context.Context, key string, now, resetBefore time.Time) (int, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	Clear(ctx context.Context, key string) error
+}
+
+// loginAttemptRepository implements LoginAttemptRepository using GORM.
+type loginAttemptRepository struct {
+	db *gorm.DB
+}
+
+// NewLoginAttemptRepository wires a GORM-backed login attempt repository.
+func NewLoginAttemptRepository(db *gorm.DB) LoginAttemptRepository {
+	return &loginAttemptRepository{db: db}
+}
+
+func (r *loginAttemptRepository) Find(ctx context.Context, keys ...string) ([]entity.LoginAttempt, error) {
+	var out []entity.LoginAttempt
+	if err := r.db.WithContext(ctx).Where("key IN ?", keys).Find(&out).Error; err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *loginAttemptRepository) RecordFailure(ctx context.Context, key string, now, resetBefore time.Time) (int, error) {
+	failures := clause.Column{Table: clause.CurrentTable, Name: "failures"}
+	lastFailure := clause.Column{Table: clause.CurrentTable, Name: "last_failure_at"}
+
+	attempt := entity.LoginAttempt{Key: key, Failures: 1, LastFailureAt: now}
+	err := r.db.WithContext(ctx).
+		Clauses(
+			clause.OnConflict{
+				Columns: []clause.Column{{Name: "key"}},
+				DoUpdates: clause.Set{
+					{Column: clause.Column{Name: "failures"}, Value: gorm.Expr("CASE WHEN ? < ? THEN 1 ELSE ? + 1 END", lastFailure, resetBefore, failures)},
+					{Column: clause.Column{Name: "last_failure_at"}, Value: now},
+				},
+			},
+			clause.Returning{Columns: []clause.Column{{Name: "failures"}}},
+		).
+		Create(&attempt).Error
+	if err != nil {
+		return 0, err
+	}
+	return attempt.Failures, nil
+}
+
+func (r *loginAttemptRepository) Lock(ctx context.Context, key string, until time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&entity.LoginAttempt{}).
+		Where("key = ?", key).
+		Update("locked_until", until).Error
+}
+
+func (r *loginAttemptRepository) Clear(ctx context.Context, key string) error {
+	return r.db.WithContext(ctx).Where("key = ?", key).Delete(&entity.LoginAttempt{}).Error
+}
@@ -0,0 +1,60 @@
+package repo
+
+import (
+	"auth/internal/entity"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PasswordResetRepository defines persistence operations for password reset tokens.
+type PasswordResetRepository interface {
+	Create(ctx context.Context, token *entity.PasswordResetToken) error
+	FindByHash(ctx context.Context, hash string) (*entity.PasswordResetToken, error)
+	// MarkUsed consumes an unused token. It reports false when the token was already used.
+	MarkUsed(ctx context.Context, id uuid.UUID) (bool, error)
+	// InvalidateForUser consumes every outstanding token of a user.
+	InvalidateForUser(ctx context.Context, userID uuid.UUID) error
+}
+
+// passwordResetRepository implements PasswordResetRepository using GORM.
+type passwordResetRepository struct {
+	db *gorm.DB
+}
+
+// NewPasswordResetRepository wires a GORM-backed password reset token repository.
+func NewPasswordResetRepository(db *gorm.DB) PasswordResetRepository {
+	return &passwordResetRepository{db: db}
+}
+
+func (r *passwordResetRepository) Create(ctx context.Context, token *entity.PasswordResetToken) error {
+	return r.db.WithContext(ctx).Create(token).Error
+}
+
+func (r *passwordResetRepository) FindByHash(ctx context.Context, hash string) (*entity.PasswordResetToken, error) {
+	var token entity.PasswordResetToken
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *passwordResetRepository) MarkUsed(ctx context.Context, id uuid.UUID) (bool, error) {
+	res := r.db.WithContext(ctx).
+		Model(&entity.PasswordResetToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now().UTC())
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+func (r *passwordResetRepository) InvalidateForUser(ctx context.Context, userID uuid.UUID) error {
+	return r.db.WithContext(ctx).
+		Model(&entity.PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now().UTC()).Error
+}
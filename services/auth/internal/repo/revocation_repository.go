@@ -0,0 +1,93 @@
+package repo
+
+import (
+	"auth/internal/entity"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RevocationRepository persists revoked access token IDs and user-wide revocations.
+type RevocationRepository interface {
+	Revoke(ctx context.Context, token *entity.RevokedToken) error
+	// ListSince returns revocations made at or after since that have not expired yet.
+	ListSince(ctx context.Context, since, now time.Time) ([]entity.RevokedToken, error)
+	// IsRevoked lets the auth service's own TokenManager reject revoked tokens.
+	IsRevoked(jti string) bool
+	// RevokeUser stores or replaces the user's revocation.
+	RevokeUser(ctx context.Context, revocation *entity.UserRevocation) error
+	// ListUsersSince is ListSince for user-wide revocations.
+	ListUsersSince(ctx context.Context, since, now time.Time) ([]entity.UserRevocation, error)
+	// RevokedBefore lets the auth service's own TokenManager reject tokens of revoked users.
+	RevokedBefore(userID string) time.Time
+}
+
+// revocationRepository implements RevocationRepository using GORM.
+type revocationRepository struct {
+	db *gorm.DB
+}
+
+// NewRevocationRepository wires a GORM-backed revocation repository.
+func NewRevocationRepository(db *gorm.DB) RevocationRepository {
+	return &revocationRepository{db: db}
+}
+
+func (r *revocationRepository) Revoke(ctx context.Context, token *entity.RevokedToken) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(token).Error
+}
+
+func (r *revocationRepository) ListSince(ctx context.Context, since, now time.Time) ([]entity.RevokedToken, error) {
+	var out []entity.RevokedToken
+	if err := r.db.WithContext(ctx).
+		Where("revoked_at >= ? AND expires_at > ?", since, now).
+		Order("revoked_at ASC").
+		Find(&out).Error; err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *revocationRepository) IsRevoked(jti string) bool {
+	var count int64
+	if err := r.db.Model(&entity.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error; err != nil {
+		return false
+	}
+	return count > 0
+}
+
+func (r *revocationRepository) RevokeUser(ctx context.Context, revocation *entity.UserRevocation) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"revoked_at", "expires_at"}),
+		}).
+		Create(revocation).Error
+}
+
+func (r *revocationRepository) ListUsersSince(ctx context.Context, since, now time.Time) ([]entity.UserRevocation, error) {
+	var out []entity.UserRevocation
+	if err := r.db.WithContext(ctx).
+		Where("revoked_at >= ? AND expires_at > ?", since, now).
+		Order("revoked_at ASC").
+		Find(&out).Error; err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *revocationRepository) RevokedBefore(userID string) time.Time {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return time.Time{}
+	}
+	var revocation entity.UserRevocation
+	if err := r.db.Where("user_id = ? AND expires_at > ?", id, time.Now().UTC()).First(&revocation).Error; err != nil {
+		return time.Time{}
+	}
+	return revocation.RevokedAt
+}
@@ -0,0 +1,116 @@
+package repo
+
+import (
+	"auth/internal/entity"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RecoveryCodeRepository defines persistence operations for 2FA recovery codes.
+type RecoveryCodeRepository interface {
+	// Replace deletes the user's codes and stores the given ones.
+	Replace(ctx context.Context, userID uuid.UUID, codes []entity.RecoveryCode) error
+	// Use consumes an unused code of the user. It reports false when no such code exists.
+	Use(ctx context.Context, userID uuid.UUID, hash string) (bool, error)
+	DeleteForUser(ctx context.Context, userID uuid.UUID) error
+}
+
+// LoginChallengeRepository defines persistence operations for pending 2FA logins.
+type LoginChallengeRepository interface {
+	Create(ctx context.Context, challenge *entity.LoginChallenge) error
+	FindByHash(ctx context.Context, hash string) (*entity.LoginChallenge, error)
+	// CountAttempt increments the attempt counter of an unused challenge and returns the new count.
+	CountAttempt(ctx context.Context, id uuid.UUID) (int, error)
+	// MarkUsed consumes an unused challenge. It reports false when it was already used.
+	MarkUsed(ctx context.Context, id uuid.UUID) (bool, error)
+}
+
+// recoveryCodeRepository implements RecoveryCodeRepository using GORM.
+type recoveryCodeRepository struct {
+	db *gorm.DB
+}
+
+// NewRecoveryCodeRepository wires a GORM-backed recovery code repository.
+func NewRecoveryCodeRepository(db *gorm.DB) RecoveryCodeRepository {
+	return &recoveryCodeRepository{db: db}
+}
+
+func (r *recoveryCodeRepository) Replace(ctx context.Context, userID uuid.UUID, codes []entity.RecoveryCode) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&entity.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if len(codes) == 0 {
+			return nil
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+func (r *recoveryCodeRepository) Use(ctx context.Context, userID uuid.UUID, hash string) (bool, error) {
+	res := r.db.WithContext(ctx).
+		Model(&entity.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", time.Now().UTC())
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (r *recoveryCodeRepository) DeleteForUser(ctx context.Context, userID uuid.UUID) error {
+	return r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&entity.RecoveryCode{}).Error
+}
+
+// loginChallengeRepository implements LoginChallengeRepository using GORM.
+type loginChallengeRepository struct {
+	db *gorm.DB
+}
+
+// NewLoginChallengeRepository wires a GORM-backed login challenge repository.
+func NewLoginChallengeRepository(db *gorm.DB) LoginChallengeRepository {
+	return &loginChallengeRepository{db: db}
+}
+
+func (r *loginChallengeRepository) Create(ctx context.Context, challenge *entity.LoginChallenge) error {
+	return r.db.WithContext(ctx).Create(challenge).Error
+}
+
+func (r *loginChallengeRepository) FindByHash(ctx context.Context, hash string) (*entity.LoginChallenge, error) {
+	var challenge entity.LoginChallenge
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", hash).First(&challenge).Error; err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+func (r *loginChallengeRepository) CountAttempt(ctx context.Context, id uuid.UUID) (int, error) {
+	var challenge entity.LoginChallenge
+	res := r.db.WithContext(ctx).
+		Model(&challenge).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "attempts"}}}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	if res.Error != nil {
+		return 0, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return 0, gorm.ErrRecordNotFound
+	}
+	return challenge.Attempts, nil
+}
+
+func (r *loginChallengeRepository) MarkUsed(ctx context.Context, id uuid.UUID) (bool, error) {
+	res := r.db.WithContext(ctx).
+		Model(&entity.LoginChallenge{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now().UTC())
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
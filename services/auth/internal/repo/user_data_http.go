@@ -0,0 +1,63 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"pkg/hmacx"
+)
+
+// UserDataClient fetches the records another service keeps about a user. The records
+// are returned as raw JSON so auth does not depend on the other service's schema.
+type UserDataClient interface {
+	ListByUser(ctx context.Context, userID string) (json.RawMessage, error)
+}
+
+// userDataHTTP calls a signed internal list endpoint such as /internal/bookings?user_id=.
+type userDataHTTP struct {
+	endpoint string
+	client   *http.Client
+	signer   *hmacx.Signer
+}
+
+// NewUserDataHTTPClient returns a client for GET <baseURL><path>?user_id=<id>.
+func NewUserDataHTTPClient(baseURL, path string, signer *hmacx.Signer) UserDataClient {
+	return &userDataHTTP{
+		endpoint: baseURL + path,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		signer:   signer,
+	}
+}
+
+func (u *userDataHTTP) ListByUser(ctx context.Context, userID string) (json.RawMessage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.endpoint+"?user_id="+url.QueryEscape(userID), nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.signer.Sign(req, nil); err != nil {
+		return nil, err
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", u.endpoint, resp.Status)
+	}
+
+	var body struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	if len(body.Data) == 0 || string(body.Data) == "null" {
+		return json.RawMessage("[]"), nil
+	}
+	return body.Data, nil
+}
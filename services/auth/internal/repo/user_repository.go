@@ -3,7 +3,11 @@ package repo
 import (
 	"auth/internal/entity"
 	"context"
+	"fmt"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -11,6 +15,26 @@ import (
 type UserRepository interface {
 	Create(ctx context.Context, user *entity.User) error
 	FindByEmail(ctx context.Context, email string) (*entity.User, error)
+	FindByID(ctx context.Context, id uuid.UUID) (*entity.User, error)
+	// FindByIDs returns the users that exist among ids, in no particular order.
+	FindByIDs(ctx context.Context, ids []uuid.UUID) ([]entity.User, error)
+	// Update saves the user's self-editable profile fields.
+	Update(ctx context.Context, user *entity.User) error
+	// List returns users whose name or email contains query, newest first, with the total match count.
+	List(ctx context.Context, query string, limit, offset int) ([]entity.User, int64, error)
+	UpdateRole(ctx context.Context, id uuid.UUID, role string) error
+	// SetDisabled disables or re-enables a user. Anonymized users are never re-enabled;
+	// for them enabling reports gorm.ErrRecordNotFound.
+	SetDisabled(ctx context.Context, id uuid.UUID, disabled bool) error
+	UpdatePassword(ctx context.Context, id uuid.UUID, hashedPassword string) error
+	MarkEmailVerified(ctx context.Context, id uuid.UUID, at time.Time) error
+	// SetTOTP stores a new secret and enabled time; an empty secret turns 2FA off.
+	SetTOTP(ctx context.Context, id uuid.UUID, secret string, enabledAt *time.Time) error
+	// AdvanceTOTPStep records an accepted TOTP step. It reports false when the step is not
+	// newer than the last accepted one, i.e. the code was already used.
+	AdvanceTOTPStep(ctx context.Context, id uuid.UUID, step int64) (bool, error)
+	// Anonymize overwrites the personal data of a user and disables the account.
+	Anonymize(ctx context.Context, id uuid.UUID, hashedPassword string, at time.Time) error
 }
 
 // userRepository implements UserRepository using GORM.
@@ -34,3 +58,143 @@ func (r *userRepository) FindByEmail(ctx context.Context, email string) (*entity
 	}
 	return &user, nil
 }
+
+func (r *userRepository) FindByID(ctx context.Context, id uuid.UUID) (*entity.User, error) {
+	var user entity.User
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *userRepository) FindByIDs(ctx context.Context, ids []uuid.UUID) ([]entity.User, error) {
+	var users []entity.User
+	if err := r.db.WithContext(ctx).Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *userRepository) Update(ctx context.Context, user *entity.User) error {
+	res := r.db.WithContext(ctx).
+		Model(&entity.User{}).
+		Where("id = ?", user.ID).
+		Select("full_name").
+		Updates(user)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *userRepository) List(ctx context.Context, query string, limit, offset int) ([]entity.User, int64, error) {
+	q := r.db.WithContext(ctx).Model(&entity.User{})
+	if query != "" {
+		like := "%" + escapeLike(strings.ToLower(query)) + "%"
+		q = q.Where(`LOWER(full_name) LIKE ? ESCAPE '\' OR email LIKE ? ESCAPE '\'`, like, like)
+	}
+
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var users []entity.User
+	if err := q.Order("created_at DESC").Limit(limit).Offset(offset).Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+// likeEscaper makes a search term match literally inside a LIKE pattern with ESCAPE '\'.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func escapeLike(term string) string { return likeEscaper.Replace(term) }
+
+func (r *userRepository) UpdateRole(ctx context.Context, id uuid.UUID, role string) error {
+	return r.updateColumn(ctx, id, "role", role)
+}
+
+func (r *userRepository) SetDisabled(ctx context.Context, id uuid.UUID, disabled bool) error {
+	if disabled {
+		return r.updateColumn(ctx, id, "disabled", true)
+	}
+	res := r.db.WithContext(ctx).Model(&entity.User{}).
+		Where("id = ? AND anonymized_at IS NULL", id).
+		Update("disabled", false)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *userRepository) UpdatePassword(ctx context.Context, id uuid.UUID, hashedPassword string) error {
+	return r.updateColumn(ctx, id, "hashed_password", hashedPassword)
+}
+
+func (r *userRepository) MarkEmailVerified(ctx context.Context, id uuid.UUID, at time.Time) error {
+	return r.updateColumn(ctx, id, "email_verified_at", at)
+}
+
+func (r *userRepository) SetTOTP(ctx context.Context, id uuid.UUID, secret string, enabledAt *time.Time) error {
+	res := r.db.WithContext(ctx).Model(&entity.User{}).Where("id = ?", id).Updates(map[string]any{
+		"totp_secret":     secret,
+		"totp_enabled_at": enabledAt,
+		"totp_last_step":  0,
+	})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *userRepository) AdvanceTOTPStep(ctx context.Context, id uuid.UUID, step int64) (bool, error) {
+	res := r.db.WithContext(ctx).
+		Model(&entity.User{}).
+		Where("id = ? AND totp_last_step < ?", id, step).
+		Update("totp_last_step", step)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+func (r *userRepository) Anonymize(ctx context.Context, id uuid.UUID, hashedPassword string, at time.Time) error {
+	res := r.db.WithContext(ctx).Model(&entity.User{}).Where("id = ?", id).Updates(map[string]any{
+		"full_name":         "Deleted user",
+		"email":             fmt.Sprintf("deleted-%s@deleted.invalid", id),
+		"hashed_password":   hashedPassword,
+		"disabled":          true,
+		"email_verified_at": nil,
+		"totp_secret":       "",
+		"totp_enabled_at":   nil,
+		"anonymized_at":     at,
+	})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *userRepository) updateColumn(ctx context.Context, id uuid.UUID, column string, value any) error {
+	res := r.db.WithContext(ctx).Model(&entity.User{}).Where("id = ?", id).Update(column, value)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
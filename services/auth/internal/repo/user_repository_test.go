@@ -0,0 +1,21 @@
+package repo
+
+import "testing"
+
+func TestEscapeLike(t *testing.T) {
+	tests := []struct {
+		term string
+		want string
+	}{
+		{term: "guest", want: "guest"},
+		{term: "100%", want: `100\%`},
+		{term: "first_last", want: `first\_last`},
+		{term: `back\slash`, want: `back\\slash`},
+		{term: `\%_`, want: `\\\%\_`},
+	}
+	for _, tt := range tests {
+		if got := escapeLike(tt.term); got != tt.want {
+			t.Errorf("escapeLike(%q) = %q, want %q", tt.term, got, tt.want)
+		}
+	}
+}
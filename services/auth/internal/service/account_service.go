@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"auth/internal/entity"
+	"auth/internal/repo"
+
+	"pkg/bcryptx"
+
+	"github.com/google/uuid"
+)
+
+// RecentAuthWindow is how long after signing in a user without a usable password,
+// such as one created through an OpenID provider, may delete the account.
+const RecentAuthWindow = 5 * time.Minute
+
+var (
+	ErrReauthenticationRequired = errors.New("sign in again or give your password to confirm")
+	ErrActiveBookings           = errors.New("account has upcoming or ongoing bookings; cancel them before deleting the account")
+	ErrAccountDataUnavailable   = errors.New("could not collect account data from the booking or payment service")
+)
+
+// activeBookingStatuses block account deletion since the stay still needs the guest's details.
+var activeBookingStatuses = map[string]struct{}{
+	"UNPAID":     {},
+	"PAID":       {},
+	"CHECKED_IN": {},
+	// a cancellation whose refund has not gone through yet
+	"REFUND_PENDING": {},
+}
+
+// AccountExport is the personal data archive handed to a user on request.
+type AccountExport struct {
+	ExportedAt time.Time         `json:"exported_at"`
+	Profile    *ProfilePayload   `json:"profile"`
+	Identities []entity.Identity `json:"identities"`
+	Bookings   json.RawMessage   `json:"bookings"`
+	Payments   json.RawMessage   `json:"payments"`
+}
+
+// AccountService handles data-subject requests: export and deletion of one's own account.
+type AccountService interface {
+	Export(ctx context.Context, userID string) (*AccountExport, error)
+	// Delete anonymizes the account after checking the password or, for accounts linked to
+	// an OpenID provider, that authTime is within RecentAuthWindow. Bookings and payments
+	// are kept for accounting; they only reference the now anonymous user ID.
+	Delete(ctx context.Context, userID, password string, authTime time.Time) error
+}
+
+type accountService struct {
+	repo          repo.UserRepository
+	sessions      repo.RefreshTokenRepository
+	revocations   repo.RevocationRepository
+	identities    repo.IdentityRepository
+	recovery      repo.RecoveryCodeRepository
+	resets        repo.PasswordResetRepository
+	verifications repo.EmailVerificationRepository
+	bookings      repo.UserDataClient
+	payments      repo.UserDataClient
+	accessTTL     time.Duration
+}
+
+// NewAccountService wires data-subject requests; accessTTL is the lifetime of access
+// tokens, for which the revocation of a deleted account has to be kept.
+func NewAccountService(repo repo.UserRepository, sessions repo.RefreshTokenRepository, revocations repo.RevocationRepository, identities repo.IdentityRepository, recovery repo.RecoveryCodeRepository, resets repo.PasswordResetRepository, verifications repo.EmailVerificationRepository, bookings, payments repo.UserDataClient, accessTTL time.Duration) AccountService {
+	return &accountService{
+		repo:          repo,
+		sessions:      sessions,
+		revocations:   revocations,
+		identities:    identities,
+		recovery:      recovery,
+		resets:        resets,
+		verifications: verifications,
+		bookings:      bookings,
+		payments:      payments,
+		accessTTL:     accessTTL,
+	}
+}
+
+func (svc *accountService) Export(ctx context.Context, userID string) (*AccountExport, error) {
+	user, err := svc.user(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	identities, err := svc.identities.ListByUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	bookings, err := svc.bookings.ListByUser(ctx, user.ID.String())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAccountDataUnavailable, err)
+	}
+	payments, err := svc.payments.ListByUser(ctx, user.ID.String())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAccountDataUnavailable, err)
+	}
+
+	return &AccountExport{
+		ExportedAt: time.Now().UTC(),
+		Profile:    toProfilePayload(user),
+		Identities: identities,
+		Bookings:   bookings,
+		Payments:   payments,
+	}, nil
+}
+
+func (svc *accountService) Delete(ctx context.Context, userID, password string, authTime time.Time) error {
+	user, err := svc.user(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := svc.reauthenticate(ctx, user, password, authTime); err != nil {
+		return err
+	}
+	if err := svc.checkNoActiveBookings(ctx, user.ID.String()); err != nil {
+		return err
+	}
+
+	unusable, err := newOpaqueToken()
+	if err != nil {
+		return err
+	}
+	hashed, err := bcryptx.HashPassword(unusable)
+	if err != nil {
+		return err
+	}
+	if err := svc.repo.Anonymize(ctx, user.ID, hashed, time.Now().UTC()); err != nil {
+		return notFound(err)
+	}
+
+	if err := svc.sessions.RevokeAllForUser(ctx, user.ID); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	if err := svc.revocations.RevokeUser(ctx, &entity.UserRevocation{
+		UserID:    user.ID,
+		RevokedAt: now,
+		ExpiresAt: now.Add(svc.accessTTL),
+	}); err != nil {
+		return err
+	}
+	if err := svc.identities.DeleteForUser(ctx, user.ID); err != nil {
+		return err
+	}
+	if err := svc.recovery.DeleteForUser(ctx, user.ID); err != nil {
+		return err
+	}
+	if err := svc.resets.InvalidateForUser(ctx, user.ID); err != nil {
+		return err
+	}
+	return svc.verifications.InvalidateForUser(ctx, user.ID)
+}
+
+// reauthenticate confirms that the caller is the account holder. Accounts created through
+// an OpenID provider have a random password, so a fresh login there counts instead.
+func (svc *accountService) reauthenticate(ctx context.Context, user *entity.User, password string, authTime time.Time) error {
+	if password != "" {
+		if err := bcryptx.CompareHash(user.HashedPassword, password); err != nil {
+			return ErrIncorrectPassword
+		}
+		return nil
+	}
+
+	identities, err := svc.identities.ListByUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if len(identities) == 0 || authTime.IsZero() || time.Since(authTime) > RecentAuthWindow {
+		return ErrReauthenticationRequired
+	}
+	return nil
+}
+
+func (svc *accountService) checkNoActiveBookings(ctx context.Context, userID string) error {
+	raw, err := svc.bookings.ListByUser(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrAccountDataUnavailable, err)
+	}
+	var bookings []struct {
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(raw, &bookings); err != nil {
+		return fmt.Errorf("%w: %v", ErrAccountDataUnavailable, err)
+	}
+	for _, b := range bookings {
+		if _, ok := activeBookingStatuses[b.Status]; ok {
+			return ErrActiveBookings
+		}
+	}
+	return nil
+}
+
+func (svc *accountService) user(ctx context.Context, userID string) (*entity.User, error) {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	user, err := svc.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return user, nil
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"auth/internal/entity"
+	"auth/internal/repo"
+
+	"github.com/google/uuid"
+)
+
+func (m *memUsers) Anonymize(_ context.Context, id uuid.UUID, hashedPassword string, at time.Time) error {
+	u := m.byID[id]
+	u.FullName, u.Email, u.HashedPassword = "Deleted user", "deleted-"+id.String()+"@deleted.invalid", hashedPassword
+	u.Disabled, u.AnonymizedAt = true, &at
+	return nil
+}
+
+func (m *memIdentities) ListByUser(_ context.Context, userID uuid.UUID) ([]entity.Identity, error) {
+	var out []entity.Identity
+	for _, identity := range m.list {
+		if identity.UserID == userID {
+			out = append(out, identity)
+		}
+	}
+	return out, nil
+}
+
+func (m *memIdentities) DeleteForUser(_ context.Context, userID uuid.UUID) error {
+	kept := m.list[:0]
+	for _, identity := range m.list {
+		if identity.UserID != userID {
+			kept = append(kept, identity)
+		}
+	}
+	m.list = kept
+	return nil
+}
+
+func (noRecoveryCodes) DeleteForUser(context.Context, uuid.UUID) error { return nil }
+
+// memUserRevocations records user-wide revocations.
+type memUserRevocations struct {
+	repo.RevocationRepository
+	users map[uuid.UUID]entity.UserRevocation
+}
+
+func (m *memUserRevocations) RevokeUser(_ context.Context, revocation *entity.UserRevocation) error {
+	m.users[revocation.UserID] = *revocation
+	return nil
+}
+
+// noResets and noVerifications are token stores without outstanding tokens.
+type noResets struct{ repo.PasswordResetRepository }
+type noVerifications struct {
+	repo.EmailVerificationRepository
+}
+
+func (noResets) InvalidateForUser(context.Context, uuid.UUID) error        { return nil }
+func (noVerifications) InvalidateForUser(context.Context, uuid.UUID) error { return nil }
+
+// noUserData is a booking or payment service with nothing for any user.
+type noUserData struct{}
+
+func (noUserData) ListByUser(context.Context, string) (json.RawMessage, error) {
+	return json.RawMessage("[]"), nil
+}
+
+type accountFixture struct {
+	svc         AccountService
+	users       *memUsers
+	revocations *memUserRevocations
+	user        *entity.User
+}
+
+// newAccountFixture creates one user signed up through an OpenID provider, i.e. without a known password.
+func newAccountFixture(t *testing.T) *accountFixture {
+	t.Helper()
+	users := &memUsers{byID: map[uuid.UUID]*entity.User{}}
+	user := &entity.User{FullName: "Guest", Email: "guest@example.com", Role: RoleUser, HashedPassword: "$2a$10$unusable"}
+	_ = users.Create(context.Background(), user)
+	identities := &memIdentities{list: []entity.Identity{{UserID: user.ID, Issuer: "https://idp.test", Subject: "guest"}}}
+	revocations := &memUserRevocations{users: map[uuid.UUID]entity.UserRevocation{}}
+	sessions := &memSessions{byID: map[uuid.UUID]*entity.RefreshToken{}}
+
+	svc := NewAccountService(users, sessions, revocations, identities, noRecoveryCodes{}, noResets{}, noVerifications{}, noUserData{}, noUserData{}, 15*time.Minute)
+	return &accountFixture{svc: svc, users: users, revocations: revocations, user: user}
+}
+
+func TestDeleteAccountWithRecentOIDCLogin(t *testing.T) {
+	f := newAccountFixture(t)
+
+	if err := f.svc.Delete(context.Background(), f.user.ID.String(), "", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if f.users.byID[f.user.ID].AnonymizedAt == nil {
+		t.Error("user was not anonymized")
+	}
+	revocation, ok := f.revocations.users[f.user.ID]
+	if !ok {
+		t.Fatal("access tokens of the deleted user were not revoked")
+	}
+	if !revocation.ExpiresAt.After(time.Now().Add(14 * time.Minute)) {
+		t.Errorf("revocation expires at %s, want it kept for the access token TTL", revocation.ExpiresAt)
+	}
+}
+
+func TestDeleteAccountRequiresReauthentication(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		authTime time.Time
+		want     error
+	}{
+		{name: "no auth time", want: ErrReauthenticationRequired},
+		{name: "stale login", authTime: time.Now().Add(-RecentAuthWindow - time.Minute), want: ErrReauthenticationRequired},
+		{name: "wrong password", password: "guess", authTime: time.Now(), want: ErrIncorrectPassword},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newAccountFixture(t)
+			if err := f.svc.Delete(context.Background(), f.user.ID.String(), tt.password, tt.authTime); !errors.Is(err, tt.want) {
+				t.Fatalf("Delete() error = %v, want %v", err, tt.want)
+			}
+			if f.users.byID[f.user.ID].AnonymizedAt != nil {
+				t.Error("user was anonymized")
+			}
+		})
+	}
+}
+
+func TestDeleteAccountWithoutIdentityNeedsPassword(t *testing.T) {
+	f := newAccountFixture(t)
+	_ = f.svc.(*accountService).identities.DeleteForUser(context.Background(), f.user.ID)
+
+	if err := f.svc.Delete(context.Background(), f.user.ID.String(), "", time.Now()); !errors.Is(err, ErrReauthenticationRequired) {
+		t.Fatalf("Delete() error = %v, want %v", err, ErrReauthenticationRequired)
+	}
+}
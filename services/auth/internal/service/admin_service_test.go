@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"auth/internal/entity"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func (m *memUsers) SetDisabled(_ context.Context, id uuid.UUID, disabled bool) error {
+	u, ok := m.byID[id]
+	if !ok || (!disabled && u.AnonymizedAt != nil) {
+		return gorm.ErrRecordNotFound
+	}
+	u.Disabled = disabled
+	return nil
+}
+
+func TestSetDisabledRefusesToEnableDeletedAccount(t *testing.T) {
+	users := &memUsers{byID: map[uuid.UUID]*entity.User{}}
+	deletedAt := time.Now().UTC()
+	deleted := &entity.User{FullName: "Deleted user", Email: "deleted@deleted.invalid", Role: RoleUser, Disabled: true, AnonymizedAt: &deletedAt}
+	_ = users.Create(context.Background(), deleted)
+	svc := NewUserAdminService(users, nil, nil, time.Minute)
+
+	if _, err := svc.SetDisabled(context.Background(), uuid.NewString(), deleted.ID.String(), false); !errors.Is(err, ErrAccountDeleted) {
+		t.Fatalf("SetDisabled(false) error = %v, want %v", err, ErrAccountDeleted)
+	}
+	if !users.byID[deleted.ID].Disabled {
+		t.Error("deleted account was re-enabled")
+	}
+	if _, err := svc.SetDisabled(context.Background(), uuid.NewString(), uuid.NewString(), false); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("SetDisabled(false) for unknown user error = %v, want %v", err, ErrUserNotFound)
+	}
+}
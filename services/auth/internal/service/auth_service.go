@@ -2,8 +2,13 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"strings"
+	"time"
 
 	"auth/internal/entity"
 	"auth/internal/repo"
@@ -11,6 +16,7 @@ import (
 	"pkg/bcryptx"
 	"pkg/jwtx"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -28,9 +34,15 @@ var allowedRoles = map[string]struct{}{
 
 const defaultRole = RoleUser
 
+// DefaultRefreshTTL is the lifetime of refresh tokens when not configured.
+const DefaultRefreshTTL = 30 * 24 * time.Hour
+
 var (
 	ErrEmailAlreadyUsed   = errors.New("email already registered")
 	ErrInvalidCredentials = errors.New("invalid email or password")
+	ErrInvalidRefresh     = errors.New("invalid or expired refresh token")
+	ErrRefreshReused      = errors.New("refresh token reuse detected; session revoked")
+	ErrAccountDisabled    = errors.New("account is disabled")
 )
 
 type RegisterInput struct {
@@ -43,11 +55,19 @@ type RegisterInput struct {
 type LoginInput struct {
 	Email    string
 	Password string
+	// ClientIP is used for per-IP throttling; it may be empty.
+	ClientIP string
 }
 
+// AuthResult carries the issued tokens. For users with 2FA enabled, Login instead
+// sets TwoFactorRequired and a ChallengeToken to pass to LoginTwoFactor.
 type AuthResult struct {
-	AccessToken string       `json:"access_token"`
-	User        *UserPayload `json:"user"`
+	AccessToken       string       `json:"access_token,omitempty"`
+	RefreshToken      string       `json:"refresh_token,omitempty"`
+	ExpiresIn         int64        `json:"expires_in,omitempty"`
+	TwoFactorRequired bool         `json:"two_factor_required,omitempty"`
+	ChallengeToken    string       `json:"challenge_token,omitempty"`
+	User              *UserPayload `json:"user"`
 }
 
 // RegistrationResult contains only the created user payload (no token)
@@ -56,23 +76,54 @@ type RegistrationResult struct {
 }
 
 type UserPayload struct {
-	ID       string `json:"id"`
-	FullName string `json:"full_name"`
-	Email    string `json:"email"`
+	ID            string `json:"id"`
+	FullName      string `json:"full_name"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
 }
 
 type AuthService interface {
 	Register(ctx context.Context, input RegisterInput) (*RegistrationResult, error)
 	Login(ctx context.Context, input LoginInput) (*AuthResult, error)
+	// LoginTwoFactor completes a login that returned a challenge token.
+	LoginTwoFactor(ctx context.Context, challengeToken, code string) (*AuthResult, error)
+	// CompleteLogin signs in a user whose identity was proven elsewhere, such as by an
+	// OpenID provider. Disabled accounts and 2FA are handled as in Login.
+	CompleteLogin(ctx context.Context, user *entity.User) (*AuthResult, error)
+	Refresh(ctx context.Context, refreshToken string) (*AuthResult, error)
+	Logout(ctx context.Context, refreshToken, accessToken string) error
+	RevokeAccessToken(ctx context.Context, claims *jwtx.AccessClaims) error
+	Revocations(ctx context.Context, since time.Time) (*RevocationList, error)
+	// LookupUsers returns the users found among ids for other services; unknown IDs are skipped.
+	LookupUsers(ctx context.Context, ids []string) ([]UserPayload, error)
+}
+
+// MaxLookupUsers bounds the number of IDs accepted by LookupUsers.
+const MaxLookupUsers = 100
+
+// RevocationList is served to other services so they can reject revoked access tokens.
+type RevocationList struct {
+	AsOf    time.Time               `json:"as_of"`
+	Revoked []entity.RevokedToken   `json:"revoked"`
+	Users   []entity.UserRevocation `json:"users"`
 }
 
 type authService struct {
-	repo   repo.UserRepository
-	tokens *jwtx.TokenManager
+	repo        repo.UserRepository
+	sessions    repo.RefreshTokenRepository
+	revocations repo.RevocationRepository
+	tokens      *jwtx.TokenManager
+	throttle    *LoginThrottle
+	twoFactor   TwoFactorService
+	refreshTTL  time.Duration
 }
 
-func NewAuthService(repo repo.UserRepository, tokens *jwtx.TokenManager) AuthService {
-	return &authService{repo: repo, tokens: tokens}
+// NewAuthService wires the auth use cases. A nil throttle disables login throttling.
+func NewAuthService(repo repo.UserRepository, sessions repo.RefreshTokenRepository, revocations repo.RevocationRepository, tokens *jwtx.TokenManager, throttle *LoginThrottle, twoFactor TwoFactorService, refreshTTL time.Duration) AuthService {
+	if refreshTTL <= 0 {
+		refreshTTL = DefaultRefreshTTL
+	}
+	return &authService{repo: repo, sessions: sessions, revocations: revocations, tokens: tokens, throttle: throttle, twoFactor: twoFactor, refreshTTL: refreshTTL}
 }
 
 func (svc *authService) Register(ctx context.Context, input RegisterInput) (*RegistrationResult, error) {
@@ -123,33 +174,282 @@ func (svc *authService) Login(ctx context.Context, input LoginInput) (*AuthResul
 		return nil, ErrInvalidCredentials
 	}
 
+	if svc.throttle != nil {
+		if err := svc.throttle.Check(ctx, email, input.ClientIP); err != nil {
+			return nil, err
+		}
+	}
+
 	user, err := svc.repo.FindByEmail(ctx, email)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrInvalidCredentials
+			return nil, svc.loginFailed(ctx, email, input.ClientIP)
 		}
 		return nil, err
 	}
 
 	if err := bcryptx.CompareHash(user.HashedPassword, input.Password); err != nil {
-		return nil, ErrInvalidCredentials
+		return nil, svc.loginFailed(ctx, email, input.ClientIP)
+	}
+
+	// with 2FA the password alone is not a successful login; LoginTwoFactor clears the counter
+	if svc.throttle != nil && !user.IsTwoFactorEnabled() {
+		if err := svc.throttle.Success(ctx, email); err != nil {
+			return nil, err
+		}
+	}
+
+	return svc.CompleteLogin(ctx, user)
+}
+
+func (svc *authService) CompleteLogin(ctx context.Context, user *entity.User) (*AuthResult, error) {
+	if user.Disabled {
+		return nil, ErrAccountDisabled
+	}
+
+	if user.IsTwoFactorEnabled() {
+		challenge, err := svc.twoFactor.StartChallenge(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+		result := buildAuthResult(user, "")
+		result.TwoFactorRequired = true
+		result.ChallengeToken = challenge
+		return result, nil
+	}
+
+	return svc.issueTokens(ctx, user)
+}
+
+func (svc *authService) LoginTwoFactor(ctx context.Context, challengeToken, code string) (*AuthResult, error) {
+	user, err := svc.twoFactor.RedeemChallenge(ctx, challengeToken, code)
+	if err != nil {
+		return nil, err
+	}
+	if user.Disabled {
+		return nil, ErrAccountDisabled
+	}
+	if svc.throttle != nil {
+		if err := svc.throttle.Success(ctx, user.Email); err != nil {
+			return nil, err
+		}
+	}
+	return svc.issueTokens(ctx, user)
+}
+
+// loginFailed counts a failed attempt towards lockout and returns ErrInvalidCredentials.
+func (svc *authService) loginFailed(ctx context.Context, email, ip string) error {
+	if svc.throttle != nil {
+		if err := svc.throttle.Failure(ctx, email, ip); err != nil {
+			return err
+		}
 	}
+	return ErrInvalidCredentials
+}
 
-	token, err := svc.tokens.SignToken(user.ID.String(), user.Email, user.Role)
+// Refresh rotates a refresh token and issues a new access token. Presenting a token
+// that was already rotated revokes its whole family, since it may have been stolen.
+func (svc *authService) Refresh(ctx context.Context, refreshToken string) (*AuthResult, error) {
+	current, err := svc.sessions.FindByHash(ctx, hashToken(refreshToken))
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidRefresh
+		}
 		return nil, err
 	}
 
-	return buildAuthResult(user, token), nil
+	if current.RevokedAt != nil {
+		if current.ReplacedByID != nil {
+			if err := svc.sessions.RevokeFamily(ctx, current.FamilyID); err != nil {
+				return nil, err
+			}
+			return nil, ErrRefreshReused
+		}
+		return nil, ErrInvalidRefresh
+	}
+	if time.Now().UTC().After(current.ExpiresAt) {
+		return nil, ErrInvalidRefresh
+	}
+
+	user, err := svc.repo.FindByID(ctx, current.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidRefresh
+		}
+		return nil, err
+	}
+	if user.Disabled {
+		return nil, ErrAccountDisabled
+	}
+
+	result, next, err := svc.newTokens(ctx, user, current.FamilyID, current.AuthenticatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	rotated, err := svc.sessions.Rotate(ctx, current.ID, next.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !rotated {
+		// lost a race with another refresh of the same token
+		if err := svc.sessions.RevokeFamily(ctx, current.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshReused
+	}
+
+	return result, nil
+}
+
+// Logout revokes every refresh token issued from the same login and, when given,
+// the access token the caller is still holding.
+func (svc *authService) Logout(ctx context.Context, refreshToken, accessToken string) error {
+	current, err := svc.sessions.FindByHash(ctx, hashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidRefresh
+		}
+		return err
+	}
+	if err := svc.sessions.RevokeFamily(ctx, current.FamilyID); err != nil {
+		return err
+	}
+
+	if accessToken == "" {
+		return nil
+	}
+	claims, err := svc.tokens.VerifyToken(accessToken)
+	if err != nil {
+		// already invalid or expired: nothing left to revoke
+		return nil
+	}
+	return svc.RevokeAccessToken(ctx, claims)
+}
+
+// RevokeAccessToken adds the token's jti to the revocation list until it expires.
+func (svc *authService) RevokeAccessToken(ctx context.Context, claims *jwtx.AccessClaims) error {
+	if claims == nil || claims.ID == "" {
+		return nil
+	}
+	now := time.Now().UTC()
+	expiresAt := now.Add(svc.tokens.AccessTTL)
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+	userID, _ := uuid.Parse(claims.UserID)
+	return svc.revocations.Revoke(ctx, &entity.RevokedToken{
+		JTI:       claims.ID,
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+		RevokedAt: now,
+	})
+}
+
+// Revocations lists unexpired revocations made since the given time.
+func (svc *authService) Revocations(ctx context.Context, since time.Time) (*RevocationList, error) {
+	now := time.Now().UTC()
+	list, err := svc.revocations.ListSince(ctx, since, now)
+	if err != nil {
+		return nil, err
+	}
+	users, err := svc.revocations.ListUsersSince(ctx, since, now)
+	if err != nil {
+		return nil, err
+	}
+	return &RevocationList{AsOf: now, Revoked: list, Users: users}, nil
+}
+
+func (svc *authService) LookupUsers(ctx context.Context, ids []string) ([]UserPayload, error) {
+	if len(ids) == 0 || len(ids) > MaxLookupUsers {
+		return nil, ErrInvalidUserInput
+	}
+	parsed := make([]uuid.UUID, 0, len(ids))
+	for _, raw := range ids {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return nil, ErrInvalidUserInput
+		}
+		parsed = append(parsed, id)
+	}
+
+	users, err := svc.repo.FindByIDs(ctx, parsed)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]UserPayload, 0, len(users))
+	for i := range users {
+		out = append(out, *toUserPayload(&users[i]))
+	}
+	return out, nil
+}
+
+// issueTokens starts a refresh family for a login that just happened and returns the token pair.
+func (svc *authService) issueTokens(ctx context.Context, user *entity.User) (*AuthResult, error) {
+	now := time.Now().UTC()
+	result, _, err := svc.newTokens(ctx, user, uuid.Nil, &now)
+	return result, err
+}
+
+// newTokens signs an access token and stores a refresh token in familyID (a new family
+// for uuid.Nil). authTime is the time of the login the family started with, if known.
+func (svc *authService) newTokens(ctx context.Context, user *entity.User, familyID uuid.UUID, authTime *time.Time) (*AuthResult, *entity.RefreshToken, error) {
+	var signedIn time.Time
+	if authTime != nil {
+		signedIn = *authTime
+	}
+	access, err := svc.tokens.SignToken(user.ID.String(), user.Email, user.Role, user.IsEmailVerified(), signedIn)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	raw, err := newOpaqueToken()
+	if err != nil {
+		return nil, nil, err
+	}
+	session := &entity.RefreshToken{
+		UserID:          user.ID,
+		FamilyID:        familyID,
+		TokenHash:       hashToken(raw),
+		ExpiresAt:       time.Now().UTC().Add(svc.refreshTTL),
+		AuthenticatedAt: authTime,
+	}
+	if err := svc.sessions.Create(ctx, session); err != nil {
+		return nil, nil, err
+	}
+
+	result := buildAuthResult(user, access)
+	result.RefreshToken = raw
+	result.ExpiresIn = int64(svc.tokens.AccessTTL.Seconds())
+	return result, session, nil
+}
+
+// newOpaqueToken returns 32 random bytes encoded as URL-safe base64.
+func newOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
 }
 
 func buildAuthResult(user *entity.User, token string) *AuthResult {
 	return &AuthResult{
 		AccessToken: token,
-		User: &UserPayload{
-			ID:       user.ID.String(),
-			FullName: user.FullName,
-			Email:    user.Email,
-		},
+		User:        toUserPayload(user),
+	}
+}
+
+func toUserPayload(user *entity.User) *UserPayload {
+	return &UserPayload{
+		ID:            user.ID.String(),
+		FullName:      user.FullName,
+		Email:         user.Email,
+		EmailVerified: user.IsEmailVerified(),
 	}
 }
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"auth/internal/entity"
+
+	"pkg/jwtx"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// memSessions is an in-memory repo.RefreshTokenRepository.
+type memSessions struct {
+	byID map[uuid.UUID]*entity.RefreshToken
+}
+
+func (m *memSessions) Create(_ context.Context, token *entity.RefreshToken) error {
+	_ = token.BeforeCreate(nil)
+	cp := *token
+	m.byID[token.ID] = &cp
+	return nil
+}
+
+func (m *memSessions) FindByHash(_ context.Context, hash string) (*entity.RefreshToken, error) {
+	for _, t := range m.byID {
+		if t.TokenHash == hash {
+			cp := *t
+			return &cp, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *memSessions) Rotate(_ context.Context, id, replacedBy uuid.UUID) (bool, error) {
+	t, ok := m.byID[id]
+	if !ok || t.RevokedAt != nil {
+		return false, nil
+	}
+	now := time.Now().UTC()
+	t.RevokedAt = &now
+	t.ReplacedByID = &replacedBy
+	return true, nil
+}
+
+func (m *memSessions) RevokeFamily(_ context.Context, familyID uuid.UUID) error {
+	return m.revokeWhere(func(t *entity.RefreshToken) bool { return t.FamilyID == familyID })
+}
+
+func (m *memSessions) RevokeAllForUser(_ context.Context, userID uuid.UUID) error {
+	return m.revokeWhere(func(t *entity.RefreshToken) bool { return t.UserID == userID })
+}
+
+func (m *memSessions) revokeWhere(match func(*entity.RefreshToken) bool) error {
+	now := time.Now().UTC()
+	for _, t := range m.byID {
+		if t.RevokedAt == nil && match(t) {
+			t.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+// active counts the sessions that are not revoked.
+func (m *memSessions) active() int {
+	n := 0
+	for _, t := range m.byID {
+		if t.RevokedAt == nil {
+			n++
+		}
+	}
+	return n
+}
+
+type sessionFixture struct {
+	svc      *authService
+	sessions *memSessions
+	user     *entity.User
+}
+
+func newSessionFixture() *sessionFixture {
+	users := &memUsers{byID: map[uuid.UUID]*entity.User{}}
+	user := &entity.User{FullName: "Guest", Email: "guest@example.com", Role: RoleUser}
+	_ = users.Create(context.Background(), user)
+	sessions := &memSessions{byID: map[uuid.UUID]*entity.RefreshToken{}}
+	svc := NewAuthService(users, sessions, nil, jwtx.New("test-secret", "auth"), nil, nil, 0).(*authService)
+	return &sessionFixture{svc: svc, sessions: sessions, user: user}
+}
+
+func (f *sessionFixture) login(t *testing.T) *AuthResult {
+	t.Helper()
+	result, err := f.svc.CompleteLogin(context.Background(), f.user)
+	if err != nil {
+		t.Fatalf("CompleteLogin() error = %v", err)
+	}
+	return result
+}
+
+func (f *sessionFixture) session(t *testing.T, refreshToken string) *entity.RefreshToken {
+	t.Helper()
+	session, err := f.sessions.FindByHash(context.Background(), hashToken(refreshToken))
+	if err != nil {
+		t.Fatalf("no session for refresh token: %v", err)
+	}
+	return session
+}
+
+func TestRefreshRotatesToken(t *testing.T) {
+	f := newSessionFixture()
+	first := f.login(t)
+
+	second, err := f.svc.Refresh(context.Background(), first.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken || second.AccessToken == "" {
+		t.Fatal("Refresh() did not issue a new token pair")
+	}
+
+	old, next := f.session(t, first.RefreshToken), f.session(t, second.RefreshToken)
+	if old.RevokedAt == nil || old.ReplacedByID == nil || *old.ReplacedByID != next.ID {
+		t.Errorf("rotated session = %+v, want revoked and replaced by %s", old, next.ID)
+	}
+	if next.FamilyID != old.FamilyID || next.RevokedAt != nil {
+		t.Errorf("new session = %+v, want active in family %s", next, old.FamilyID)
+	}
+
+	// the access tokens of a refreshed family keep the time of the original login
+	before, err := f.svc.tokens.VerifyToken(first.AccessToken)
+	if err != nil {
+		t.Fatalf("VerifyToken() error = %v", err)
+	}
+	after, err := f.svc.tokens.VerifyToken(second.AccessToken)
+	if err != nil {
+		t.Fatalf("VerifyToken() error = %v", err)
+	}
+	if before.AuthTime == nil || after.AuthTime == nil || !after.AuthTime.Equal(before.AuthTime.Time) {
+		t.Errorf("auth_time = %v after refresh, want %v", after.AuthTime, before.AuthTime)
+	}
+
+	if _, err := f.svc.Refresh(context.Background(), second.RefreshToken); err != nil {
+		t.Fatalf("second Refresh() error = %v", err)
+	}
+}
+
+func TestRefreshReuseRevokesFamily(t *testing.T) {
+	f := newSessionFixture()
+	stolen := f.login(t)
+	other := f.login(t)
+
+	rotated, err := f.svc.Refresh(context.Background(), stolen.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	latest, err := f.svc.Refresh(context.Background(), rotated.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	if _, err := f.svc.Refresh(context.Background(), stolen.RefreshToken); !errors.Is(err, ErrRefreshReused) {
+		t.Fatalf("replayed Refresh() error = %v, want %v", err, ErrRefreshReused)
+	}
+	if _, err := f.svc.Refresh(context.Background(), latest.RefreshToken); !errors.Is(err, ErrInvalidRefresh) {
+		t.Errorf("Refresh() of the newest token in the chain error = %v, want %v", err, ErrInvalidRefresh)
+	}
+	if f.sessions.active() != 1 || f.session(t, other.RefreshToken).RevokedAt != nil {
+		t.Errorf("active sessions = %d, want only the other login", f.sessions.active())
+	}
+}
+
+func TestRefreshRejectsUnknownAndExpiredTokens(t *testing.T) {
+	f := newSessionFixture()
+	result := f.login(t)
+	for _, s := range f.sessions.byID {
+		s.ExpiresAt = time.Now().UTC().Add(-time.Second)
+	}
+
+	if _, err := f.svc.Refresh(context.Background(), result.RefreshToken); !errors.Is(err, ErrInvalidRefresh) {
+		t.Errorf("expired Refresh() error = %v, want %v", err, ErrInvalidRefresh)
+	}
+	if _, err := f.svc.Refresh(context.Background(), "not-a-token"); !errors.Is(err, ErrInvalidRefresh) {
+		t.Errorf("unknown Refresh() error = %v, want %v", err, ErrInvalidRefresh)
+	}
+}
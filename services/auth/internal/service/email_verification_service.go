@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"auth/internal/entity"
+	"auth/internal/notify"
+	"auth/internal/repo"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DefaultEmailVerificationTTL is how long a verification token stays valid when not configured.
+const DefaultEmailVerificationTTL = 24 * time.Hour
+
+var ErrInvalidVerificationToken = errors.New("invalid or expired verification token")
+
+// EmailVerificationService confirms that users own the email address they registered with.
+type EmailVerificationService interface {
+	// SendVerification mails a fresh token to the user unless they are already verified.
+	SendVerification(ctx context.Context, userID string) error
+	// Resend is SendVerification by email address. It never reports whether the account exists.
+	Resend(ctx context.Context, email string) error
+	// Verify consumes the token and marks the user's email as verified.
+	Verify(ctx context.Context, token string) error
+}
+
+type emailVerificationService struct {
+	repo     repo.UserRepository
+	tokens   repo.EmailVerificationRepository
+	notifier notify.Notifier
+	ttl      time.Duration
+}
+
+func NewEmailVerificationService(repo repo.UserRepository, tokens repo.EmailVerificationRepository, notifier notify.Notifier, ttl time.Duration) EmailVerificationService {
+	if ttl <= 0 {
+		ttl = DefaultEmailVerificationTTL
+	}
+	return &emailVerificationService{repo: repo, tokens: tokens, notifier: notifier, ttl: ttl}
+}
+
+func (svc *emailVerificationService) SendVerification(ctx context.Context, userID string) error {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	user, err := svc.repo.FindByID(ctx, id)
+	if err != nil {
+		return notFound(err)
+	}
+	return svc.send(ctx, user)
+}
+
+func (svc *emailVerificationService) Resend(ctx context.Context, email string) error {
+	email = strings.TrimSpace(strings.ToLower(email))
+	user, err := svc.repo.FindByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if user.Disabled {
+		return nil
+	}
+	if err := svc.send(ctx, user); err != nil {
+		// the caller cannot tell whether the account exists, so only log
+		log.Printf("send email verification to user %s: %v", user.ID, err)
+	}
+	return nil
+}
+
+func (svc *emailVerificationService) send(ctx context.Context, user *entity.User) error {
+	if user.IsEmailVerified() {
+		return nil
+	}
+
+	// only the most recently mailed token is usable
+	if err := svc.tokens.InvalidateForUser(ctx, user.ID); err != nil {
+		return err
+	}
+
+	raw, err := newOpaqueToken()
+	if err != nil {
+		return err
+	}
+	token := &entity.EmailVerificationToken{
+		UserID:    user.ID,
+		TokenHash: hashToken(raw),
+		ExpiresAt: time.Now().UTC().Add(svc.ttl),
+	}
+	if err := svc.tokens.Create(ctx, token); err != nil {
+		return err
+	}
+
+	return svc.notifier.Send(ctx, notify.Message{
+		To:      user.Email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf("Use this token to verify your email: %s\nIt expires at %s.",
+			raw, token.ExpiresAt.Format(time.RFC1123)),
+	})
+}
+
+func (svc *emailVerificationService) Verify(ctx context.Context, token string) error {
+	verification, err := svc.tokens.FindByHash(ctx, hashToken(token))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidVerificationToken
+		}
+		return err
+	}
+	if verification.UsedAt != nil || time.Now().UTC().After(verification.ExpiresAt) {
+		return ErrInvalidVerificationToken
+	}
+
+	used, err := svc.tokens.MarkUsed(ctx, verification.ID)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidVerificationToken
+	}
+
+	if err := svc.repo.MarkEmailVerified(ctx, verification.UserID, time.Now().UTC()); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidVerificationToken
+		}
+		return err
+	}
+	return nil
+}
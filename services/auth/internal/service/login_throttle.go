@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"auth/internal/repo"
+
+	"github.com/google/uuid"
+)
+
+var ErrTooManyAttempts = errors.New("too many failed login attempts")
+
+// LockoutError is returned by Login while an account or client IP is locked out.
+type LockoutError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockoutError) Error() string {
+	return fmt.Sprintf("%s; retry after %s", ErrTooManyAttempts, e.RetryAfter.Round(time.Second))
+}
+
+func (e *LockoutError) Unwrap() error { return ErrTooManyAttempts }
+
+// ThrottlePolicy controls when failed logins for one key start locking it out.
+// After FreeAttempts consecutive failures each further failure locks the key for
+// BaseDelay, doubling per failure up to MaxDelay. Failures older than ResetAfter are forgotten.
+type ThrottlePolicy struct {
+	FreeAttempts int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	ResetAfter   time.Duration
+}
+
+// Delay returns the lockout that follows the given number of consecutive failures.
+func (p ThrottlePolicy) Delay(failures int) time.Duration {
+	over := failures - p.FreeAttempts
+	if over <= 0 {
+		return 0
+	}
+	delay := float64(p.BaseDelay) * math.Pow(2, float64(over-1))
+	if delay > float64(p.MaxDelay) {
+		return p.MaxDelay
+	}
+	return time.Duration(delay)
+}
+
+var (
+	// DefaultAccountThrottle applies to failed logins for one email address.
+	DefaultAccountThrottle = ThrottlePolicy{FreeAttempts: 5, BaseDelay: 30 * time.Second, MaxDelay: time.Hour, ResetAfter: 24 * time.Hour}
+	// DefaultIPThrottle applies to failed logins from one client IP across all accounts.
+	DefaultIPThrottle = ThrottlePolicy{FreeAttempts: 20, BaseDelay: 30 * time.Second, MaxDelay: time.Hour, ResetAfter: 24 * time.Hour}
+)
+
+// LoginThrottle tracks failed logins per account and per client IP in the database
+// so lockouts survive restarts and are shared by every auth replica.
+type LoginThrottle struct {
+	repo    repo.LoginAttemptRepository
+	account ThrottlePolicy
+	ip      ThrottlePolicy
+	clock   func() time.Time
+}
+
+func NewLoginThrottle(repo repo.LoginAttemptRepository, account, ip ThrottlePolicy) *LoginThrottle {
+	return &LoginThrottle{repo: repo, account: account, ip: ip, clock: func() time.Time { return time.Now().UTC() }}
+}
+
+// Check returns a *LockoutError when the email or the IP is currently locked out.
+func (t *LoginThrottle) Check(ctx context.Context, email, ip string) error {
+	return t.check(ctx, t.keys(email, ip)...)
+}
+
+// CheckTwoFactor returns a *LockoutError while second-factor codes for the user are locked out.
+func (t *LoginThrottle) CheckTwoFactor(ctx context.Context, userID uuid.UUID) error {
+	return t.check(ctx, twoFactorKey(userID))
+}
+
+// TwoFactorFailure records a wrong second-factor code. Failures are counted per user
+// across challenges under the account policy, so starting new logins does not reset them.
+func (t *LoginThrottle) TwoFactorFailure(ctx context.Context, userID uuid.UUID) error {
+	return t.fail(ctx, twoFactorKey(userID), t.account)
+}
+
+// TwoFactorSuccess forgets the second-factor failures of the user.
+func (t *LoginThrottle) TwoFactorSuccess(ctx context.Context, userID uuid.UUID) error {
+	return t.repo.Clear(ctx, twoFactorKey(userID))
+}
+
+func (t *LoginThrottle) check(ctx context.Context, keys ...string) error {
+	attempts, err := t.repo.Find(ctx, keys...)
+	if err != nil {
+		return err
+	}
+	now := t.clock()
+	var wait time.Duration
+	for _, a := range attempts {
+		if a.LockedUntil != nil && a.LockedUntil.After(now) {
+			wait = max(wait, a.LockedUntil.Sub(now))
+		}
+	}
+	if wait > 0 {
+		return &LockoutError{RetryAfter: wait}
+	}
+	return nil
+}
+
+// Failure records a failed login for the email and the IP and locks whichever crossed its policy.
+func (t *LoginThrottle) Failure(ctx context.Context, email, ip string) error {
+	if err := t.fail(ctx, accountKey(email), t.account); err != nil {
+		return err
+	}
+	if ip == "" {
+		return nil
+	}
+	return t.fail(ctx, ipKey(ip), t.ip)
+}
+
+// Success forgets the failures of the account. IP failures are kept so that one valid
+// login cannot reset the counter for guesses against other accounts.
+func (t *LoginThrottle) Success(ctx context.Context, email string) error {
+	return t.repo.Clear(ctx, accountKey(email))
+}
+
+func (t *LoginThrottle) fail(ctx context.Context, key string, policy ThrottlePolicy) error {
+	now := t.clock()
+	failures, err := t.repo.RecordFailure(ctx, key, now, now.Add(-policy.ResetAfter))
+	if err != nil {
+		return err
+	}
+	if delay := policy.Delay(failures); delay > 0 {
+		return t.repo.Lock(ctx, key, now.Add(delay))
+	}
+	return nil
+}
+
+func (t *LoginThrottle) keys(email, ip string) []string {
+	keys := []string{accountKey(email)}
+	if ip != "" {
+		keys = append(keys, ipKey(ip))
+	}
+	return keys
+}
+
+func accountKey(email string) string { return "email:" + strings.ToLower(email) }
+func ipKey(ip string) string         { return "ip:" + ip }
+
+func twoFactorKey(userID uuid.UUID) string { return "2fa:" + userID.String() }
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"auth/internal/entity"
+)
+
+// memLoginAttempts is an in-memory repo.LoginAttemptRepository.
+type memLoginAttempts struct {
+	byKey map[string]*entity.LoginAttempt
+}
+
+func (m *memLoginAttempts) Find(_ context.Context, keys ...string) ([]entity.LoginAttempt, error) {
+	var out []entity.LoginAttempt
+	for _, key := range keys {
+		if a, ok := m.byKey[key]; ok {
+			out = append(out, *a)
+		}
+	}
+	return out, nil
+}
+
+func (m *memLoginAttempts) RecordFailure(_ context.Context, key string, now, resetBefore time.Time) (int, error) {
+	a, ok := m.byKey[key]
+	if !ok {
+		a = &entity.LoginAttempt{Key: key}
+		m.byKey[key] = a
+	}
+	if a.LastFailureAt.Before(resetBefore) {
+		a.Failures = 0
+	}
+	a.Failures++
+	a.LastFailureAt = now
+	return a.Failures, nil
+}
+
+func (m *memLoginAttempts) Lock(_ context.Context, key string, until time.Time) error {
+	if a, ok := m.byKey[key]; ok {
+		a.LockedUntil = &until
+	}
+	return nil
+}
+
+func (m *memLoginAttempts) Clear(_ context.Context, key string) error {
+	delete(m.byKey, key)
+	return nil
+}
+
+// fakeClock is a settable clock for throttle and challenge expiry.
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time          { return c.now }
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestThrottle(policy ThrottlePolicy, clock *fakeClock) (*LoginThrottle, *memLoginAttempts) {
+	attempts := &memLoginAttempts{byKey: map[string]*entity.LoginAttempt{}}
+	throttle := NewLoginThrottle(attempts, policy, policy)
+	throttle.clock = clock.Now
+	return throttle, attempts
+}
+
+func TestThrottlePolicyDelay(t *testing.T) {
+	policy := ThrottlePolicy{FreeAttempts: 3, BaseDelay: 30 * time.Second, MaxDelay: 5 * time.Minute}
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 0, want: 0},
+		{failures: 3, want: 0},
+		{failures: 4, want: 30 * time.Second},
+		{failures: 5, want: time.Minute},
+		{failures: 6, want: 2 * time.Minute},
+		{failures: 7, want: 4 * time.Minute},
+		{failures: 8, want: 5 * time.Minute},
+		{failures: 100, want: 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := policy.Delay(tt.failures); got != tt.want {
+			t.Errorf("Delay(%d) = %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLoginThrottleLocksAccount(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	throttle, _ := newTestThrottle(ThrottlePolicy{FreeAttempts: 2, BaseDelay: time.Minute, MaxDelay: time.Hour, ResetAfter: time.Hour}, clock)
+	ctx := context.Background()
+
+	for range 2 {
+		if err := throttle.Failure(ctx, "Guest@example.com", ""); err != nil {
+			t.Fatalf("Failure() error = %v", err)
+		}
+	}
+	if err := throttle.Check(ctx, "guest@example.com", ""); err != nil {
+		t.Fatalf("Check() within free attempts error = %v", err)
+	}
+
+	_ = throttle.Failure(ctx, "guest@example.com", "")
+	var lockout *LockoutError
+	if err := throttle.Check(ctx, "guest@example.com", ""); !errors.As(err, &lockout) || lockout.RetryAfter != time.Minute {
+		t.Fatalf("Check() error = %v, want a lockout of 1m", err)
+	}
+	if err := throttle.Check(ctx, "other@example.com", ""); err != nil {
+		t.Errorf("Check() for another account error = %v", err)
+	}
+
+	clock.Advance(time.Minute)
+	if err := throttle.Check(ctx, "guest@example.com", ""); err != nil {
+		t.Errorf("Check() after the lockout error = %v", err)
+	}
+	// the count continues, so the next failure doubles the lockout
+	_ = throttle.Failure(ctx, "guest@example.com", "")
+	if err := throttle.Check(ctx, "guest@example.com", ""); !errors.As(err, &lockout) || lockout.RetryAfter != 2*time.Minute {
+		t.Errorf("Check() error = %v, want a lockout of 2m", err)
+	}
+}
+
+func TestLoginThrottleForgetsOldFailures(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	throttle, attempts := newTestThrottle(ThrottlePolicy{FreeAttempts: 2, BaseDelay: time.Minute, MaxDelay: time.Hour, ResetAfter: time.Hour}, clock)
+	ctx := context.Background()
+
+	_ = throttle.Failure(ctx, "guest@example.com", "")
+	_ = throttle.Failure(ctx, "guest@example.com", "")
+	clock.Advance(time.Hour + time.Second)
+	_ = throttle.Failure(ctx, "guest@example.com", "")
+
+	if got := attempts.byKey[accountKey("guest@example.com")].Failures; got != 1 {
+		t.Errorf("failures after ResetAfter = %d, want 1", got)
+	}
+	if err := throttle.Check(ctx, "guest@example.com", ""); err != nil {
+		t.Errorf("Check() error = %v, want no lockout", err)
+	}
+}
+
+func TestLoginThrottleIPKey(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	attempts := &memLoginAttempts{byKey: map[string]*entity.LoginAttempt{}}
+	throttle := NewLoginThrottle(attempts,
+		ThrottlePolicy{FreeAttempts: 10, BaseDelay: time.Minute, MaxDelay: time.Hour, ResetAfter: time.Hour},
+		ThrottlePolicy{FreeAttempts: 2, BaseDelay: time.Minute, MaxDelay: time.Hour, ResetAfter: time.Hour})
+	throttle.clock = clock.Now
+	ctx := context.Background()
+
+	// one guess each against many accounts from the same address
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		if err := throttle.Failure(ctx, email, "203.0.113.7"); err != nil {
+			t.Fatalf("Failure() error = %v", err)
+		}
+	}
+
+	var lockout *LockoutError
+	if err := throttle.Check(ctx, "d@example.com", "203.0.113.7"); !errors.As(err, &lockout) {
+		t.Fatalf("Check() from the guessing IP error = %v, want *LockoutError", err)
+	}
+	if err := throttle.Check(ctx, "d@example.com", "198.51.100.1"); err != nil {
+		t.Errorf("Check() from another IP error = %v", err)
+	}
+	// a valid login clears the account, not the IP
+	if err := throttle.Success(ctx, "a@example.com"); err != nil {
+		t.Fatalf("Success() error = %v", err)
+	}
+	if _, ok := attempts.byKey[ipKey("203.0.113.7")]; !ok {
+		t.Error("Success() cleared the IP counter")
+	}
+	if _, ok := attempts.byKey[accountKey("a@example.com")]; ok {
+		t.Error("Success() kept the account counter")
+	}
+}
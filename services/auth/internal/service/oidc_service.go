@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"auth/internal/entity"
+	"auth/internal/oidc"
+	"auth/internal/repo"
+
+	"pkg/bcryptx"
+
+	"gorm.io/gorm"
+)
+
+// OIDCStateTTL bounds how long the user may spend at the identity provider.
+const OIDCStateTTL = 10 * time.Minute
+
+var (
+	ErrInvalidOIDCState    = errors.New("invalid or expired oidc login state")
+	ErrOIDCLoginFailed     = errors.New("oidc login failed")
+	ErrOIDCEmailRequired   = errors.New("identity provider did not return an email address")
+	ErrOIDCEmailUnverified = errors.New("an account with this email already exists and the provider has not verified the email")
+)
+
+// OIDCProvider is the identity provider the OIDC login talks to; *oidc.Provider implements it.
+type OIDCProvider interface {
+	Issuer() string
+	AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error)
+	Exchange(ctx context.Context, code, codeVerifier string) (*oidc.IDToken, error)
+}
+
+// OIDCService signs users in through an external OpenID provider using the
+// authorization code flow with PKCE.
+type OIDCService interface {
+	// Start returns the provider URL to redirect the browser to and the state it
+	// carries; the caller binds the state to the browser so the callback can check it.
+	Start(ctx context.Context) (target, state string, err error)
+	// Callback verifies the provider's response and returns the linked user. Users are
+	// provisioned on their first login, or linked by email when the provider verified it.
+	Callback(ctx context.Context, code, state string) (*entity.User, error)
+}
+
+type oidcService struct {
+	provider   OIDCProvider
+	repo       repo.UserRepository
+	identities repo.IdentityRepository
+	states     repo.OIDCStateRepository
+}
+
+func NewOIDCService(provider OIDCProvider, repo repo.UserRepository, identities repo.IdentityRepository, states repo.OIDCStateRepository) OIDCService {
+	return &oidcService{provider: provider, repo: repo, identities: identities, states: states}
+}
+
+func (svc *oidcService) Start(ctx context.Context) (string, string, error) {
+	state, err := oidc.NewCodeVerifier()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := oidc.NewCodeVerifier()
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := oidc.NewCodeVerifier()
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now().UTC()
+	// logins abandoned at the provider are never consumed; sweep them here
+	if err := svc.states.DeleteExpired(ctx, now); err != nil {
+		log.Printf("oidc: delete expired login states: %v", err)
+	}
+	if err := svc.states.Create(ctx, &entity.OIDCLoginState{
+		StateHash:    hashToken(state),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    now.Add(OIDCStateTTL),
+	}); err != nil {
+		return "", "", err
+	}
+	target, err := svc.provider.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return "", "", err
+	}
+	return target, state, nil
+}
+
+func (svc *oidcService) Callback(ctx context.Context, code, state string) (*entity.User, error) {
+	pending, err := svc.states.Consume(ctx, hashToken(state))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidOIDCState
+		}
+		return nil, err
+	}
+	if time.Now().UTC().After(pending.ExpiresAt) {
+		return nil, ErrInvalidOIDCState
+	}
+
+	token, err := svc.provider.Exchange(ctx, code, pending.CodeVerifier)
+	if err != nil {
+		if errors.Is(err, oidc.ErrExchangeFailed) || errors.Is(err, oidc.ErrInvalidIDToken) {
+			return nil, ErrOIDCLoginFailed
+		}
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(token.Nonce), []byte(pending.Nonce)) != 1 {
+		return nil, ErrOIDCLoginFailed
+	}
+
+	identity, err := svc.identities.FindBySubject(ctx, svc.provider.Issuer(), token.Subject)
+	if err == nil {
+		user, err := svc.repo.FindByID(ctx, identity.UserID)
+		if err != nil {
+			return nil, notFound(err)
+		}
+		return user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return svc.link(ctx, token)
+}
+
+// link attaches a first-time identity to the account with the same email, or
+// creates an account when there is none.
+func (svc *oidcService) link(ctx context.Context, token *oidc.IDToken) (*entity.User, error) {
+	if token.Email == "" {
+		return nil, ErrOIDCEmailRequired
+	}
+
+	user, err := svc.repo.FindByEmail(ctx, token.Email)
+	switch {
+	case err == nil:
+		// without a verified email anyone could claim an existing account at the provider
+		if !token.EmailVerified {
+			return nil, ErrOIDCEmailUnverified
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		user, err = svc.provision(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	if err := svc.identities.Create(ctx, &entity.Identity{
+		UserID:  user.ID,
+		Issuer:  svc.provider.Issuer(),
+		Subject: token.Subject,
+		Email:   token.Email,
+	}); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (svc *oidcService) provision(ctx context.Context, token *oidc.IDToken) (*entity.User, error) {
+	// the account has no usable password until the user resets it
+	unusable, err := newOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	hashed, err := bcryptx.HashPassword(unusable)
+	if err != nil {
+		return nil, err
+	}
+
+	fullName := strings.TrimSpace(token.Name)
+	if fullName == "" {
+		fullName = strings.SplitN(token.Email, "@", 2)[0]
+	}
+	user := &entity.User{
+		FullName:       fullName,
+		Email:          token.Email,
+		HashedPassword: hashed,
+		Role:           defaultRole,
+	}
+	if token.EmailVerified {
+		now := time.Now().UTC()
+		user.EmailVerifiedAt = &now
+	}
+	if err := svc.repo.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
@@ -0,0 +1,337 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"auth/internal/entity"
+	"auth/internal/oidc"
+	"auth/internal/repo"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// memUsers is an in-memory repo.UserRepository covering what the OIDC login uses.
+type memUsers struct {
+	repo.UserRepository
+	byID map[uuid.UUID]*entity.User
+}
+
+func (m *memUsers) Create(_ context.Context, user *entity.User) error {
+	if user.ID == uuid.Nil {
+		user.ID = uuid.New()
+	}
+	cp := *user
+	m.byID[user.ID] = &cp
+	return nil
+}
+
+func (m *memUsers) FindByEmail(_ context.Context, email string) (*entity.User, error) {
+	for _, u := range m.byID {
+		if u.Email == email {
+			cp := *u
+			return &cp, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *memUsers) FindByID(_ context.Context, id uuid.UUID) (*entity.User, error) {
+	if u, ok := m.byID[id]; ok {
+		cp := *u
+		return &cp, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// memIdentities is an in-memory repo.IdentityRepository.
+type memIdentities struct {
+	repo.IdentityRepository
+	list []entity.Identity
+}
+
+func (m *memIdentities) Create(_ context.Context, identity *entity.Identity) error {
+	m.list = append(m.list, *identity)
+	return nil
+}
+
+func (m *memIdentities) FindBySubject(_ context.Context, issuer, subject string) (*entity.Identity, error) {
+	for i := range m.list {
+		if m.list[i].Issuer == issuer && m.list[i].Subject == subject {
+			cp := m.list[i]
+			return &cp, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// memStates is an in-memory repo.OIDCStateRepository.
+type memStates struct {
+	byHash map[string]*entity.OIDCLoginState
+}
+
+func (m *memStates) Create(_ context.Context, state *entity.OIDCLoginState) error {
+	cp := *state
+	m.byHash[state.StateHash] = &cp
+	return nil
+}
+
+func (m *memStates) Consume(_ context.Context, hash string) (*entity.OIDCLoginState, error) {
+	state, ok := m.byHash[hash]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	delete(m.byHash, hash)
+	return state, nil
+}
+
+func (m *memStates) DeleteExpired(_ context.Context, before time.Time) error {
+	for hash, state := range m.byHash {
+		if state.ExpiresAt.Before(before) {
+			delete(m.byHash, hash)
+		}
+	}
+	return nil
+}
+
+// unverifiedEmails makes the provider report every email as unverified.
+type unverifiedEmails struct {
+	OIDCProvider
+}
+
+func (p unverifiedEmails) Exchange(ctx context.Context, code, codeVerifier string) (*oidc.IDToken, error) {
+	token, err := p.OIDCProvider.Exchange(ctx, code, codeVerifier)
+	if err != nil {
+		return nil, err
+	}
+	token.EmailVerified = false
+	return token, nil
+}
+
+type oidcFixture struct {
+	svc        *oidcService
+	users      *memUsers
+	identities *memIdentities
+	states     *memStates
+	browser    *http.Client
+}
+
+// newOIDCFixture serves a StubProvider on an httptest.Server and wires the
+// service to it through the real relying-party client.
+func newOIDCFixture(t *testing.T) *oidcFixture {
+	t.Helper()
+	var stub *oidc.StubProvider
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stub.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	stub, err := oidc.NewStubProvider(srv.URL, "hotel-test")
+	if err != nil {
+		t.Fatalf("NewStubProvider() error = %v", err)
+	}
+	provider := oidc.NewProvider(oidc.Config{
+		IssuerURL:   srv.URL,
+		ClientID:    "hotel-test",
+		RedirectURL: "http://auth.test/api/v1/auth/oidc/callback",
+	})
+
+	f := &oidcFixture{
+		users:      &memUsers{byID: map[uuid.UUID]*entity.User{}},
+		identities: &memIdentities{},
+		states:     &memStates{byHash: map[string]*entity.OIDCLoginState{}},
+		browser: &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}},
+	}
+	f.svc = NewOIDCService(provider, f.users, f.identities, f.states).(*oidcService)
+	return f
+}
+
+// authorize starts a login for email and follows the provider's redirect,
+// returning the code and state the callback would receive.
+func (f *oidcFixture) authorize(t *testing.T, email string) (code, state string) {
+	t.Helper()
+	target, state, err := f.svc.Start(context.Background())
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		t.Fatalf("parse auth URL: %v", err)
+	}
+	q := u.Query()
+	q.Set("login_hint", email)
+	u.RawQuery = q.Encode()
+
+	resp, err := f.browser.Get(u.String())
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize status = %d, want 302", resp.StatusCode)
+	}
+	back, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("parse redirect: %v", err)
+	}
+	if got := back.Query().Get("state"); got != state {
+		t.Fatalf("redirect state = %q, want %q", got, state)
+	}
+	return back.Query().Get("code"), state
+}
+
+func (f *oidcFixture) login(t *testing.T, email string) (*entity.User, error) {
+	t.Helper()
+	code, state := f.authorize(t, email)
+	return f.svc.Callback(context.Background(), code, state)
+}
+
+func (f *oidcFixture) pending(t *testing.T, state string) *entity.OIDCLoginState {
+	t.Helper()
+	p, ok := f.states.byHash[hashToken(state)]
+	if !ok {
+		t.Fatalf("no pending login for state %q", state)
+	}
+	return p
+}
+
+func TestOIDCCallbackProvisionsNewUser(t *testing.T) {
+	f := newOIDCFixture(t)
+
+	user, err := f.login(t, "New.Guest@example.com")
+	if err != nil {
+		t.Fatalf("Callback() error = %v", err)
+	}
+	if user.Email != "new.guest@example.com" || user.Role != defaultRole || user.EmailVerifiedAt == nil {
+		t.Errorf("provisioned user = %+v, want verified %s new.guest@example.com", user, defaultRole)
+	}
+	if len(f.users.byID) != 1 {
+		t.Errorf("users = %d, want 1", len(f.users.byID))
+	}
+	if len(f.identities.list) != 1 || f.identities.list[0].UserID != user.ID {
+		t.Errorf("identities = %+v, want one linked to %s", f.identities.list, user.ID)
+	}
+}
+
+func TestOIDCCallbackReusesExistingIdentity(t *testing.T) {
+	f := newOIDCFixture(t)
+
+	first, err := f.login(t, "guest@example.com")
+	if err != nil {
+		t.Fatalf("first Callback() error = %v", err)
+	}
+	second, err := f.login(t, "guest@example.com")
+	if err != nil {
+		t.Fatalf("second Callback() error = %v", err)
+	}
+	if second.ID != first.ID {
+		t.Errorf("second login user = %s, want %s", second.ID, first.ID)
+	}
+	if len(f.users.byID) != 1 || len(f.identities.list) != 1 {
+		t.Errorf("users = %d, identities = %d, want 1 and 1", len(f.users.byID), len(f.identities.list))
+	}
+}
+
+func TestOIDCCallbackLinksVerifiedEmail(t *testing.T) {
+	f := newOIDCFixture(t)
+	existing := &entity.User{FullName: "Guest", Email: "guest@example.com", Role: RoleStaff}
+	_ = f.users.Create(context.Background(), existing)
+
+	user, err := f.login(t, "guest@example.com")
+	if err != nil {
+		t.Fatalf("Callback() error = %v", err)
+	}
+	if user.ID != existing.ID || user.Role != RoleStaff {
+		t.Errorf("linked user = %s (%s), want %s (%s)", user.ID, user.Role, existing.ID, RoleStaff)
+	}
+	if len(f.users.byID) != 1 {
+		t.Errorf("users = %d, want 1", len(f.users.byID))
+	}
+	if len(f.identities.list) != 1 || f.identities.list[0].UserID != existing.ID {
+		t.Errorf("identities = %+v, want one linked to %s", f.identities.list, existing.ID)
+	}
+}
+
+func TestOIDCCallbackRefusesUnverifiedEmail(t *testing.T) {
+	f := newOIDCFixture(t)
+	f.svc.provider = unverifiedEmails{f.svc.provider}
+	_ = f.users.Create(context.Background(), &entity.User{FullName: "Guest", Email: "guest@example.com", Role: RoleUser})
+
+	if _, err := f.login(t, "guest@example.com"); !errors.Is(err, ErrOIDCEmailUnverified) {
+		t.Fatalf("Callback() error = %v, want %v", err, ErrOIDCEmailUnverified)
+	}
+	if len(f.identities.list) != 0 {
+		t.Errorf("identities = %+v, want none", f.identities.list)
+	}
+}
+
+func TestOIDCCallbackRejectsReplayedState(t *testing.T) {
+	f := newOIDCFixture(t)
+	code, state := f.authorize(t, "guest@example.com")
+
+	if _, err := f.svc.Callback(context.Background(), code, state); err != nil {
+		t.Fatalf("first Callback() error = %v", err)
+	}
+	if _, err := f.svc.Callback(context.Background(), code, state); !errors.Is(err, ErrInvalidOIDCState) {
+		t.Fatalf("replayed Callback() error = %v, want %v", err, ErrInvalidOIDCState)
+	}
+}
+
+func TestOIDCCallbackRejectsExpiredState(t *testing.T) {
+	f := newOIDCFixture(t)
+	code, state := f.authorize(t, "guest@example.com")
+	f.pending(t, state).ExpiresAt = time.Now().UTC().Add(-time.Second)
+
+	if _, err := f.svc.Callback(context.Background(), code, state); !errors.Is(err, ErrInvalidOIDCState) {
+		t.Fatalf("Callback() error = %v, want %v", err, ErrInvalidOIDCState)
+	}
+	if len(f.users.byID) != 0 {
+		t.Errorf("users = %d, want 0", len(f.users.byID))
+	}
+}
+
+func TestOIDCStartSweepsExpiredStates(t *testing.T) {
+	f := newOIDCFixture(t)
+	_, stale := f.authorize(t, "guest@example.com")
+	f.pending(t, stale).ExpiresAt = time.Now().UTC().Add(-time.Second)
+
+	_, fresh := f.authorize(t, "guest@example.com")
+	if _, ok := f.states.byHash[hashToken(stale)]; ok {
+		t.Error("expired login state was not deleted")
+	}
+	f.pending(t, fresh)
+}
+
+func TestOIDCCallbackRejectsNonceMismatch(t *testing.T) {
+	f := newOIDCFixture(t)
+	code, state := f.authorize(t, "guest@example.com")
+	f.pending(t, state).Nonce = "another-login"
+
+	if _, err := f.svc.Callback(context.Background(), code, state); !errors.Is(err, ErrOIDCLoginFailed) {
+		t.Fatalf("Callback() error = %v, want %v", err, ErrOIDCLoginFailed)
+	}
+	if len(f.users.byID) != 0 {
+		t.Errorf("users = %d, want 0", len(f.users.byID))
+	}
+}
+
+func TestOIDCCallbackRejectsBadCodeVerifier(t *testing.T) {
+	f := newOIDCFixture(t)
+	code, state := f.authorize(t, "guest@example.com")
+	f.pending(t, state).CodeVerifier = "not-the-verifier-the-challenge-was-made-from"
+
+	if _, err := f.svc.Callback(context.Background(), code, state); !errors.Is(err, ErrOIDCLoginFailed) {
+		t.Fatalf("Callback() error = %v, want %v", err, ErrOIDCLoginFailed)
+	}
+	if len(f.users.byID) != 0 {
+		t.Errorf("users = %d, want 0", len(f.users.byID))
+	}
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"auth/internal/entity"
+	"auth/internal/notify"
+	"auth/internal/repo"
+
+	"pkg/bcryptx"
+
+	"gorm.io/gorm"
+)
+
+// DefaultPasswordResetTTL is how long a reset token stays valid when not configured.
+const DefaultPasswordResetTTL = time.Hour
+
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+// PasswordResetService lets users who forgot their password set a new one.
+type PasswordResetService interface {
+	// RequestReset sends a reset token to the email when it belongs to an active account.
+	// It never reports whether the account exists.
+	RequestReset(ctx context.Context, email string) error
+	// ConfirmReset consumes the token, sets the new password and ends all refresh sessions.
+	ConfirmReset(ctx context.Context, token, newPassword string) error
+}
+
+type passwordResetService struct {
+	repo     repo.UserRepository
+	resets   repo.PasswordResetRepository
+	sessions repo.RefreshTokenRepository
+	notifier notify.Notifier
+	ttl      time.Duration
+}
+
+func NewPasswordResetService(repo repo.UserRepository, resets repo.PasswordResetRepository, sessions repo.RefreshTokenRepository, notifier notify.Notifier, ttl time.Duration) PasswordResetService {
+	if ttl <= 0 {
+		ttl = DefaultPasswordResetTTL
+	}
+	return &passwordResetService{repo: repo, resets: resets, sessions: sessions, notifier: notifier, ttl: ttl}
+}
+
+func (svc *passwordResetService) RequestReset(ctx context.Context, email string) error {
+	email = strings.TrimSpace(strings.ToLower(email))
+	user, err := svc.repo.FindByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if user.Disabled {
+		return nil
+	}
+
+	// only the most recently mailed token is usable
+	if err := svc.resets.InvalidateForUser(ctx, user.ID); err != nil {
+		return err
+	}
+
+	raw, err := newOpaqueToken()
+	if err != nil {
+		return err
+	}
+	token := &entity.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: hashToken(raw),
+		ExpiresAt: time.Now().UTC().Add(svc.ttl),
+	}
+	if err := svc.resets.Create(ctx, token); err != nil {
+		return err
+	}
+
+	msg := notify.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Use this token to reset your password: %s\nIt expires at %s.",
+			raw, token.ExpiresAt.Format(time.RFC1123)),
+	}
+	if err := svc.notifier.Send(ctx, msg); err != nil {
+		// the caller cannot tell whether the account exists, so only log
+		log.Printf("send password reset to user %s: %v", user.ID, err)
+	}
+	return nil
+}
+
+func (svc *passwordResetService) ConfirmReset(ctx context.Context, token, newPassword string) error {
+	if strings.TrimSpace(newPassword) == "" {
+		return ErrInvalidUserInput
+	}
+
+	reset, err := svc.resets.FindByHash(ctx, hashToken(token))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+	if reset.UsedAt != nil || time.Now().UTC().After(reset.ExpiresAt) {
+		return ErrInvalidResetToken
+	}
+
+	used, err := svc.resets.MarkUsed(ctx, reset.ID)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidResetToken
+	}
+
+	hashed, err := bcryptx.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	if err := svc.repo.UpdatePassword(ctx, reset.UserID, hashed); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+	return svc.sessions.RevokeAllForUser(ctx, reset.UserID)
+}
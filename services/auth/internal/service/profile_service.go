@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"auth/internal/entity"
+	"auth/internal/repo"
+
+	"pkg/bcryptx"
+
+	"github.com/google/uuid"
+)
+
+var ErrIncorrectPassword = errors.New("current password is incorrect")
+
+// ProfilePayload is the signed-in user's own view of their account.
+type ProfilePayload struct {
+	ID               string    `json:"id"`
+	FullName         string    `json:"full_name"`
+	Email            string    `json:"email"`
+	Role             string    `json:"role"`
+	EmailVerified    bool      `json:"email_verified"`
+	TwoFactorEnabled bool      `json:"two_factor_enabled"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+type UpdateProfileInput struct {
+	FullName string
+}
+
+// ProfileService lets users read and edit their own account.
+type ProfileService interface {
+	Get(ctx context.Context, userID string) (*ProfilePayload, error)
+	Update(ctx context.Context, userID string, input UpdateProfileInput) (*ProfilePayload, error)
+	// ChangePassword requires the current password and signs out every refresh session.
+	ChangePassword(ctx context.Context, userID, currentPassword, newPassword string) error
+}
+
+type profileService struct {
+	repo     repo.UserRepository
+	sessions repo.RefreshTokenRepository
+}
+
+func NewProfileService(repo repo.UserRepository, sessions repo.RefreshTokenRepository) ProfileService {
+	return &profileService{repo: repo, sessions: sessions}
+}
+
+func (svc *profileService) Get(ctx context.Context, userID string) (*ProfilePayload, error) {
+	user, err := svc.user(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return toProfilePayload(user), nil
+}
+
+func (svc *profileService) Update(ctx context.Context, userID string, input UpdateProfileInput) (*ProfilePayload, error) {
+	user, err := svc.user(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	fullName := strings.TrimSpace(input.FullName)
+	if fullName == "" {
+		return nil, ErrInvalidUserInput
+	}
+	user.FullName = fullName
+	if err := svc.repo.Update(ctx, user); err != nil {
+		return nil, notFound(err)
+	}
+	return toProfilePayload(user), nil
+}
+
+func (svc *profileService) ChangePassword(ctx context.Context, userID, currentPassword, newPassword string) error {
+	user, err := svc.user(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(newPassword) == "" {
+		return ErrInvalidUserInput
+	}
+	if err := bcryptx.CompareHash(user.HashedPassword, currentPassword); err != nil {
+		return ErrIncorrectPassword
+	}
+
+	hashed, err := bcryptx.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	if err := svc.repo.UpdatePassword(ctx, user.ID, hashed); err != nil {
+		return notFound(err)
+	}
+	return svc.sessions.RevokeAllForUser(ctx, user.ID)
+}
+
+func (svc *profileService) user(ctx context.Context, userID string) (*entity.User, error) {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	user, err := svc.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return user, nil
+}
+
+func toProfilePayload(user *entity.User) *ProfilePayload {
+	return &ProfilePayload{
+		ID:               user.ID.String(),
+		FullName:         user.FullName,
+		Email:            user.Email,
+		Role:             user.Role,
+		EmailVerified:    user.IsEmailVerified(),
+		TwoFactorEnabled: user.IsTwoFactorEnabled(),
+		CreatedAt:        user.CreatedAt,
+	}
+}
@@ -0,0 +1,323 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"auth/internal/entity"
+	"auth/internal/repo"
+
+	"pkg/totpx"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// LoginChallengeTTL is how long the second login step may take.
+	LoginChallengeTTL = 5 * time.Minute
+	// maxChallengeAttempts bounds code guesses per challenge.
+	maxChallengeAttempts = 5
+	recoveryCodeCount    = 10
+	// totpSkew accepts codes from one step before or after the current one.
+	totpSkew = 1
+)
+
+var (
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnrolled    = errors.New("two-factor authentication is not enrolled")
+	ErrTwoFactorNotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrInvalidTwoFactorCode    = errors.New("invalid two-factor code")
+	ErrInvalidChallenge        = errors.New("invalid or expired login challenge")
+)
+
+// TOTPEnrollment is returned when a user starts enrolling an authenticator app.
+type TOTPEnrollment struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+// RecoveryCodes are shown to the user once; only their hashes are stored.
+type RecoveryCodes struct {
+	Codes []string `json:"recovery_codes"`
+}
+
+// TwoFactorService manages TOTP two-factor authentication.
+type TwoFactorService interface {
+	// Enroll creates a new pending secret. 2FA is not enforced until Activate succeeds.
+	Enroll(ctx context.Context, userID string) (*TOTPEnrollment, error)
+	// Activate enables 2FA after the user proved the app works, and returns fresh recovery codes.
+	Activate(ctx context.Context, userID, code string) (*RecoveryCodes, error)
+	Disable(ctx context.Context, userID, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, userID, code string) (*RecoveryCodes, error)
+
+	// StartChallenge issues the challenge token returned by a password login.
+	StartChallenge(ctx context.Context, user *entity.User) (string, error)
+	// RedeemChallenge checks a TOTP or recovery code for the challenge and returns its user.
+	RedeemChallenge(ctx context.Context, challengeToken, code string) (*entity.User, error)
+}
+
+type twoFactorService struct {
+	repo       repo.UserRepository
+	codes      repo.RecoveryCodeRepository
+	challenges repo.LoginChallengeRepository
+	throttle   *LoginThrottle
+	issuer     string
+	clock      func() time.Time
+}
+
+// NewTwoFactorService wires 2FA. issuer is the account label shown in authenticator apps.
+// throttle limits wrong login codes per user; nil leaves only the per-challenge limit.
+func NewTwoFactorService(repo repo.UserRepository, codes repo.RecoveryCodeRepository, challenges repo.LoginChallengeRepository, throttle *LoginThrottle, issuer string) TwoFactorService {
+	return &twoFactorService{
+		repo:       repo,
+		codes:      codes,
+		challenges: challenges,
+		throttle:   throttle,
+		issuer:     issuer,
+		clock:      func() time.Time { return time.Now().UTC() },
+	}
+}
+
+func (svc *twoFactorService) Enroll(ctx context.Context, userID string) (*TOTPEnrollment, error) {
+	user, err := svc.user(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsTwoFactorEnabled() {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := totpx.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := svc.repo.SetTOTP(ctx, user.ID, secret, nil); err != nil {
+		return nil, notFound(err)
+	}
+	return &TOTPEnrollment{Secret: secret, OTPAuthURI: totpx.URI(svc.issuer, user.Email, secret)}, nil
+}
+
+func (svc *twoFactorService) Activate(ctx context.Context, userID, code string) (*RecoveryCodes, error) {
+	user, err := svc.user(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsTwoFactorEnabled() {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+	// recovery codes do not exist yet, so only a TOTP code proves the app is set up
+	if err := svc.checkTOTP(ctx, user, code); err != nil {
+		return nil, err
+	}
+
+	now := svc.clock()
+	if err := svc.repo.SetTOTP(ctx, user.ID, user.TOTPSecret, &now); err != nil {
+		return nil, notFound(err)
+	}
+	return svc.newRecoveryCodes(ctx, user.ID)
+}
+
+func (svc *twoFactorService) Disable(ctx context.Context, userID, code string) error {
+	user, err := svc.enabledUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := svc.checkCode(ctx, user, code); err != nil {
+		return err
+	}
+	if err := svc.repo.SetTOTP(ctx, user.ID, "", nil); err != nil {
+		return notFound(err)
+	}
+	return svc.codes.DeleteForUser(ctx, user.ID)
+}
+
+func (svc *twoFactorService) RegenerateRecoveryCodes(ctx context.Context, userID, code string) (*RecoveryCodes, error) {
+	user, err := svc.enabledUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := svc.checkCode(ctx, user, code); err != nil {
+		return nil, err
+	}
+	return svc.newRecoveryCodes(ctx, user.ID)
+}
+
+func (svc *twoFactorService) StartChallenge(ctx context.Context, user *entity.User) (string, error) {
+	if svc.throttle != nil {
+		if err := svc.throttle.CheckTwoFactor(ctx, user.ID); err != nil {
+			return "", err
+		}
+	}
+	raw, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	challenge := &entity.LoginChallenge{
+		UserID:    user.ID,
+		TokenHash: hashToken(raw),
+		ExpiresAt: svc.clock().Add(LoginChallengeTTL),
+	}
+	if err := svc.challenges.Create(ctx, challenge); err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+func (svc *twoFactorService) RedeemChallenge(ctx context.Context, challengeToken, code string) (*entity.User, error) {
+	challenge, err := svc.challenges.FindByHash(ctx, hashToken(challengeToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidChallenge
+		}
+		return nil, err
+	}
+	if challenge.UsedAt != nil || svc.clock().After(challenge.ExpiresAt) {
+		return nil, ErrInvalidChallenge
+	}
+
+	attempts, err := svc.challenges.CountAttempt(ctx, challenge.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidChallenge
+		}
+		return nil, err
+	}
+	if attempts > maxChallengeAttempts {
+		return nil, ErrInvalidChallenge
+	}
+	if svc.throttle != nil {
+		if err := svc.throttle.CheckTwoFactor(ctx, challenge.UserID); err != nil {
+			return nil, err
+		}
+	}
+
+	user, err := svc.repo.FindByID(ctx, challenge.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidChallenge
+		}
+		return nil, err
+	}
+	if !user.IsTwoFactorEnabled() {
+		// 2FA was turned off after the password step
+		return nil, ErrInvalidChallenge
+	}
+	if err := svc.checkCode(ctx, user, code); err != nil {
+		if errors.Is(err, ErrInvalidTwoFactorCode) && svc.throttle != nil {
+			if ferr := svc.throttle.TwoFactorFailure(ctx, user.ID); ferr != nil {
+				return nil, ferr
+			}
+		}
+		return nil, err
+	}
+
+	used, err := svc.challenges.MarkUsed(ctx, challenge.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		return nil, ErrInvalidChallenge
+	}
+	if svc.throttle != nil {
+		if err := svc.throttle.TwoFactorSuccess(ctx, user.ID); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+// checkCode accepts either a current TOTP code or an unused recovery code.
+func (svc *twoFactorService) checkCode(ctx context.Context, user *entity.User, code string) error {
+	code = strings.TrimSpace(code)
+	if len(code) == totpx.Digits {
+		return svc.checkTOTP(ctx, user, code)
+	}
+
+	used, err := svc.codes.Use(ctx, user.ID, hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+func (svc *twoFactorService) checkTOTP(ctx context.Context, user *entity.User, code string) error {
+	step, ok := totpx.Validate(user.TOTPSecret, code, svc.clock(), totpSkew)
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+	fresh, err := svc.repo.AdvanceTOTPStep(ctx, user.ID, step)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+func (svc *twoFactorService) newRecoveryCodes(ctx context.Context, userID uuid.UUID) (*RecoveryCodes, error) {
+	out := &RecoveryCodes{Codes: make([]string, 0, recoveryCodeCount)}
+	rows := make([]entity.RecoveryCode, 0, recoveryCodeCount)
+	for range recoveryCodeCount {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		out.Codes = append(out.Codes, code)
+		rows = append(rows, entity.RecoveryCode{UserID: userID, CodeHash: hashToken(normalizeRecoveryCode(code))})
+	}
+	if err := svc.codes.Replace(ctx, userID, rows); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (svc *twoFactorService) user(ctx context.Context, userID string) (*entity.User, error) {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	user, err := svc.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return user, nil
+}
+
+func (svc *twoFactorService) enabledUser(ctx context.Context, userID string) (*entity.User, error) {
+	user, err := svc.user(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsTwoFactorEnabled() {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	return user, nil
+}
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// newRecoveryCode returns a code such as "k3x7q-m2p9a" (50 random bits).
+func newRecoveryCode() (string, error) {
+	b := make([]byte, 7)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	s := strings.ToLower(recoveryEncoding.EncodeToString(b))[:10]
+	return s[:5] + "-" + s[5:], nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
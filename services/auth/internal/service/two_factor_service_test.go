@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"auth/internal/entity"
+	"auth/internal/repo"
+
+	"pkg/bcryptx"
+	"pkg/jwtx"
+	"pkg/totpx"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func (m *memUsers) AdvanceTOTPStep(_ context.Context, id uuid.UUID, step int64) (bool, error) {
+	u, ok := m.byID[id]
+	if !ok {
+		return false, gorm.ErrRecordNotFound
+	}
+	if step <= u.TOTPLastStep {
+		return false, nil
+	}
+	u.TOTPLastStep = step
+	return true, nil
+}
+
+// memChallenges is an in-memory repo.LoginChallengeRepository.
+type memChallenges struct {
+	byHash map[string]*entity.LoginChallenge
+}
+
+func (m *memChallenges) Create(_ context.Context, challenge *entity.LoginChallenge) error {
+	challenge.ID = uuid.New()
+	cp := *challenge
+	m.byHash[challenge.TokenHash] = &cp
+	return nil
+}
+
+func (m *memChallenges) FindByHash(_ context.Context, hash string) (*entity.LoginChallenge, error) {
+	if c, ok := m.byHash[hash]; ok {
+		cp := *c
+		return &cp, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *memChallenges) find(id uuid.UUID) *entity.LoginChallenge {
+	for _, c := range m.byHash {
+		if c.ID == id && c.UsedAt == nil {
+			return c
+		}
+	}
+	return nil
+}
+
+func (m *memChallenges) CountAttempt(_ context.Context, id uuid.UUID) (int, error) {
+	c := m.find(id)
+	if c == nil {
+		return 0, gorm.ErrRecordNotFound
+	}
+	c.Attempts++
+	return c.Attempts, nil
+}
+
+func (m *memChallenges) MarkUsed(_ context.Context, id uuid.UUID) (bool, error) {
+	c := m.find(id)
+	if c == nil {
+		return false, nil
+	}
+	now := time.Now().UTC()
+	c.UsedAt = &now
+	return true, nil
+}
+
+// noRecoveryCodes is a repo.RecoveryCodeRepository without any codes.
+type noRecoveryCodes struct {
+	repo.RecoveryCodeRepository
+}
+
+func (noRecoveryCodes) Use(context.Context, uuid.UUID, string) (bool, error) { return false, nil }
+
+type twoFactorFixture struct {
+	svc      *twoFactorService
+	auth     *authService
+	attempts *memLoginAttempts
+	clock    *fakeClock
+	user     *entity.User
+}
+
+// newTwoFactorFixture enrolls one user with 2FA and locks after two wrong codes.
+func newTwoFactorFixture(t *testing.T) *twoFactorFixture {
+	t.Helper()
+	clock := &fakeClock{now: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	throttle, attempts := newTestThrottle(ThrottlePolicy{FreeAttempts: 2, BaseDelay: time.Minute, MaxDelay: time.Hour, ResetAfter: 24 * time.Hour}, clock)
+
+	secret, err := totpx.GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret() error = %v", err)
+	}
+	hashed, err := bcryptx.HashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("HashPassword() error = %v", err)
+	}
+	enabled := clock.now
+	user := &entity.User{FullName: "Guest", Email: "guest@example.com", Role: RoleUser, HashedPassword: hashed, TOTPSecret: secret, TOTPEnabledAt: &enabled}
+	users := &memUsers{byID: map[uuid.UUID]*entity.User{}}
+	_ = users.Create(context.Background(), user)
+
+	svc := NewTwoFactorService(users, noRecoveryCodes{}, &memChallenges{byHash: map[string]*entity.LoginChallenge{}}, throttle, "Hotel").(*twoFactorService)
+	svc.clock = clock.Now
+	sessions := &memSessions{byID: map[uuid.UUID]*entity.RefreshToken{}}
+	auth := NewAuthService(users, sessions, nil, jwtx.New("test-secret", "auth"), throttle, svc, 0).(*authService)
+	return &twoFactorFixture{svc: svc, auth: auth, attempts: attempts, clock: clock, user: user}
+}
+
+// code returns the TOTP code for the fixture clock.
+func (f *twoFactorFixture) code(t *testing.T) string {
+	t.Helper()
+	code, err := totpx.Code(f.user.TOTPSecret, totpx.Step(f.clock.now))
+	if err != nil {
+		t.Fatalf("Code() error = %v", err)
+	}
+	return code
+}
+
+func (f *twoFactorFixture) challenge(t *testing.T) string {
+	t.Helper()
+	token, err := f.svc.StartChallenge(context.Background(), f.user)
+	if err != nil {
+		t.Fatalf("StartChallenge() error = %v", err)
+	}
+	return token
+}
+
+func (f *twoFactorFixture) guessWrong(t *testing.T) {
+	t.Helper()
+	// each guess uses a fresh challenge, so the per-challenge limit never applies
+	if _, err := f.svc.RedeemChallenge(context.Background(), f.challenge(t), "000000"); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Fatalf("RedeemChallenge() error = %v, want %v", err, ErrInvalidTwoFactorCode)
+	}
+}
+
+func TestRedeemChallengeLocksUserAcrossChallenges(t *testing.T) {
+	f := newTwoFactorFixture(t)
+	ctx := context.Background()
+
+	f.guessWrong(t)
+	f.guessWrong(t)
+	pending := f.challenge(t)
+	f.guessWrong(t)
+
+	var lockout *LockoutError
+	if _, err := f.svc.StartChallenge(ctx, f.user); !errors.As(err, &lockout) {
+		t.Fatalf("StartChallenge() while locked error = %v, want *LockoutError", err)
+	}
+	if _, err := f.svc.RedeemChallenge(ctx, pending, f.code(t)); !errors.As(err, &lockout) {
+		t.Fatalf("RedeemChallenge() while locked error = %v, want *LockoutError", err)
+	}
+
+	f.clock.Advance(lockout.RetryAfter + time.Second)
+	if _, err := f.svc.RedeemChallenge(ctx, pending, f.code(t)); err != nil {
+		t.Fatalf("RedeemChallenge() after lockout error = %v", err)
+	}
+	if _, ok := f.attempts.byKey[twoFactorKey(f.user.ID)]; ok {
+		t.Error("second-factor failures were not cleared by a valid code")
+	}
+}
+
+func TestLoginClearsAccountFailuresOnlyAfterSecondFactor(t *testing.T) {
+	f := newTwoFactorFixture(t)
+	ctx := context.Background()
+	email := accountKey(f.user.Email)
+	if err := f.auth.throttle.Failure(ctx, f.user.Email, ""); err != nil {
+		t.Fatalf("Failure() error = %v", err)
+	}
+
+	result, err := f.auth.Login(ctx, LoginInput{Email: f.user.Email, Password: "correct horse"})
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	if !result.TwoFactorRequired {
+		t.Fatal("Login() did not ask for a second factor")
+	}
+	if _, ok := f.attempts.byKey[email]; !ok {
+		t.Fatal("password step cleared the account failures")
+	}
+
+	if _, err := f.auth.LoginTwoFactor(ctx, result.ChallengeToken, f.code(t)); err != nil {
+		t.Fatalf("LoginTwoFactor() error = %v", err)
+	}
+	if _, ok := f.attempts.byKey[email]; ok {
+		t.Error("account failures were not cleared after the second factor")
+	}
+}
@@ -9,45 +9,117 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"pkg/dbx"
+	"pkg/hmacx"
+	"pkg/httpx"
 	"pkg/jwtx"
 
 	"github.com/gin-gonic/gin"
 )
 
-type noopPay struct{}
-
-func (noopPay) RequestPayment(ctx context.Context, bookingID string, amount int64, email string) error {
-	return nil
-}
-
-func (noopPay) RefundPayment(ctx context.Context, bookingID string, amount int64, reason string) error {
-	return nil
-}
-
 func main() {
 	db, err := dbx.InitDatabase("DB_SCHEMA")
 	if err != nil {
 		log.Fatalf("connect booking database: %v", err)
 	}
 	// Auto-migrate schema (no destructive drops)
-	if err := db.AutoMigrate(&entity.Booking{}, &entity.BookingItem{}); err != nil {
+	if err := db.AutoMigrate(&entity.Booking{}, &entity.BookingItem{}, &entity.BookingNightRate{}, &entity.BookingTax{}); err != nil {
 		log.Fatalf("auto migrate booking schema: %v", err)
 	}
+	internalSecret := os.Getenv("INTERNAL_API_SECRET")
+	if internalSecret == "" {
+		log.Fatal("INTERNAL_API_SECRET env is required")
+	}
+	signer := hmacx.NewSigner("booking", internalSecret)
+
 	bookingRepo := repo.NewBookingRepository(db)
-	invRepo := repo.NewInventoryHTTPRepo("http://catalog:8002")
-	pay := noopPay{}
-	svc := service.NewService(invRepo, bookingRepo, pay)
+	catalogBase := os.Getenv("CATALOG_BASE_URL")
+	if catalogBase == "" {
+		catalogBase = "http://catalog:8002"
+	}
+	invRepo := repo.NewInventoryHTTPRepo(catalogBase, signer)
+	payments := repo.NewPaymentHTTPClient(os.Getenv("PAYMENT_BASE_URL"), signer)
+	svc := service.NewService(invRepo, bookingRepo, payments, payments)
+	if raw := os.Getenv("BOOKING_PAYMENT_TTL"); raw != "" {
+		ttl, err := time.ParseDuration(raw)
+		if err != nil {
+			log.Fatalf("invalid BOOKING_PAYMENT_TTL: %v", err)
+		}
+		svc.SetPaymentTTL(ttl)
+	}
+	if path := os.Getenv("BOOKING_TAX_RULES_FILE"); path != "" {
+		rules, err := repo.LoadTaxRules(path)
+		if err != nil {
+			log.Fatalf("load tax rules: %v", err)
+		}
+		svc.SetTaxRules(rules)
+	}
+	sweepEvery := time.Minute
+	if raw := os.Getenv("BOOKING_EXPIRY_SWEEP_INTERVAL"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			log.Fatalf("invalid BOOKING_EXPIRY_SWEEP_INTERVAL: %q", raw)
+		}
+		sweepEvery = d
+	}
+	go svc.RunExpirySweeper(context.Background(), sweepEvery)
 
 	r := gin.Default()
+	// the guest rate limits key on the client IP, which must not come from a forged header
+	if err := r.SetTrustedProxies(httpx.TrustedProxies(os.Getenv("TRUSTED_PROXIES"))); err != nil {
+		log.Fatalf("invalid TRUSTED_PROXIES: %v", err)
+	}
 	// JWT
 	secret := os.Getenv("JWT_SECRET")
 	if secret == "" {
 		secret = "dev-secret"
 	}
-	tm := jwtx.New(secret, "booking")
-	h := handler.NewHandler(svc, tm)
+	authBase := os.Getenv("AUTH_BASE_URL")
+	if authBase == "" {
+		authBase = "http://auth:8001"
+	}
+	revocations := jwtx.NewRevocationCache(authBase, signer)
+	go revocations.Run(context.Background(), 30*time.Second)
+	tokenOpts := []jwtx.Option{jwtx.WithRevocationChecker(revocations)}
+	// With a JWKS URL only auth-signed asymmetric tokens are accepted.
+	if jwksURL := os.Getenv("JWT_JWKS_URL"); jwksURL != "" {
+		tokenOpts = append(tokenOpts, jwtx.WithKeySource(jwtx.NewJWKSCache(jwksURL)))
+	}
+	tm := jwtx.New(secret, "booking", tokenOpts...)
+	h := handler.NewHandler(svc, tm, hmacx.NewVerifier(internalSecret))
+	if raw := os.Getenv("BOOKING_REQUIRE_VERIFIED_EMAIL"); raw != "" {
+		required, err := strconv.ParseBool(raw)
+		if err != nil {
+			log.Fatalf("invalid BOOKING_REQUIRE_VERIFIED_EMAIL: %q", raw)
+		}
+		h.SetRequireVerifiedEmail(required)
+	}
+	// Guest tokens are shared with Payment so guests can pay. The secret is their own:
+	// reusing the internal secret would let anyone holding it mint guest tokens and vice versa.
+	guestSecret := os.Getenv("GUEST_TOKEN_SECRET")
+	if guestSecret == "" {
+		log.Fatal("GUEST_TOKEN_SECRET env is required")
+	}
+	guestTokens := jwtx.New(guestSecret, "booking-guest")
+	guestTokens.AccessTTL = time.Hour
+	if raw := os.Getenv("GUEST_TOKEN_TTL"); raw != "" {
+		ttl, err := time.ParseDuration(raw)
+		if err != nil || ttl <= 0 {
+			log.Fatalf("invalid GUEST_TOKEN_TTL: %q", raw)
+		}
+		guestTokens.AccessTTL = ttl
+	}
+	h.SetGuestTokens(guestTokens)
+	if raw := os.Getenv("GUEST_RATE_LIMIT"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			log.Fatalf("invalid GUEST_RATE_LIMIT: %q", raw)
+		}
+		h.SetGuestRateLimit(limit)
+	}
 
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "ok"})
@@ -17,12 +17,19 @@ const (
 	StatusCheckedIn  Status = "CHECKED_IN"
 	StatusCheckedOut Status = "CHECKED_OUT"
 	StatusRefunded   Status = "REFUNDED"
+	// StatusRefundPending is a paid booking being cancelled whose refund has not gone through yet.
+	StatusRefundPending Status = "REFUND_PENDING"
 )
 
+// Booking is a reservation of one or more rooms. Guest bookings are made without an
+// account: UserID is empty and the Guest* fields hold the contact details instead.
 type Booking struct {
 	ID           string        `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
 	UserID       string        `gorm:"index" json:"user_id"`
 	Code         string        `gorm:"uniqueIndex" json:"code"`
+	GuestName    string        `gorm:"size:150" json:"guest_name,omitempty"`
+	GuestEmail   string        `gorm:"size:255;index" json:"guest_email,omitempty"`
+	GuestPhone   string        `gorm:"size:50" json:"guest_phone,omitempty"`
 	CheckInDate  time.Time     `json:"check_in_date"`
 	CheckOutDate time.Time     `json:"check_out_date"`
 	Nights       int           `json:"nights"`
@@ -31,9 +38,11 @@ type Booking struct {
 	Taxes        int64         `json:"taxes"`
 	Total        int64         `json:"total"`
 	Status       Status        `gorm:"index" json:"status"`
+	PaymentDueAt *time.Time    `gorm:"index" json:"payment_due_at,omitempty"`
 	CreatedAt    time.Time     `json:"created_at"`
 	UpdatedAt    time.Time     `json:"updated_at"`
 	Items        []BookingItem `gorm:"foreignKey:BookingID" json:"items"`
+	TaxLines     []BookingTax  `gorm:"foreignKey:BookingID" json:"tax_lines"`
 }
 
 func (b *Booking) BeforeCreate(_ *gorm.DB) error {
@@ -51,13 +60,16 @@ func (b *Booking) BeforeCreate(_ *gorm.DB) error {
 	return nil
 }
 
+// BookingItem is one room type line of a booking. PricePerNight is the average
+// nightly rate; NightlyRates holds the exact price of every night.
 type BookingItem struct {
-	ID            string `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
-	BookingID     string `gorm:"index" json:"booking_id"`
-	RoomTypeID    int    `gorm:"index" json:"room_type_id"`
-	Quantity      int    `json:"quantity"`
-	PricePerNight int64  `json:"price_per_night"`
-	LineTotal     int64  `json:"line_total"`
+	ID            string             `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	BookingID     string             `gorm:"index" json:"booking_id"`
+	RoomTypeID    int                `gorm:"index" json:"room_type_id"`
+	Quantity      int                `json:"quantity"`
+	PricePerNight int64              `json:"price_per_night"`
+	LineTotal     int64              `json:"line_total"`
+	NightlyRates  []BookingNightRate `gorm:"foreignKey:BookingItemID" json:"nightly_rates"`
 }
 
 func (bi *BookingItem) BeforeCreate(_ *gorm.DB) error {
@@ -67,17 +79,33 @@ func (bi *BookingItem) BeforeCreate(_ *gorm.DB) error {
 	return nil
 }
 
+// BookingNightRate snapshots the per-room price of a single night for a booking item.
+type BookingNightRate struct {
+	ID            string    `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	BookingItemID string    `gorm:"index" json:"booking_item_id"`
+	Date          time.Time `gorm:"type:date" json:"date"`
+	Price         int64     `json:"price"`
+}
+
+func (nr *BookingNightRate) BeforeCreate(_ *gorm.DB) error {
+	if nr.ID == "" {
+		nr.ID = uuid.New().String()
+	}
+	return nil
+}
+
 type CreateBookingItem struct {
 	RoomTypeID int `json:"room_type_id" binding:"required"`
 	Quantity   int `json:"quantity" binding:"required,min=1"`
 }
 
 type CreateBookingInput struct {
-	UserID   string              // set by handler from JWT
+	UserID   string              // set by handler from JWT; empty for guest bookings
 	CheckIn  time.Time           `json:"check_in" binding:"required"`
 	CheckOut time.Time           `json:"check_out" binding:"required"`
 	Guests   int                 `json:"guests"`
 	FullName string              `json:"full_name"`
-	Email    string              // set by handler from JWT
+	Email    string              // set by handler from JWT or the guest form
+	Phone    string              `json:"phone"`
 	Items    []CreateBookingItem `json:"items" binding:"required,min=1,dive"`
 }
@@ -2,24 +2,45 @@ package entity
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
+// ErrNoAvailability is returned by InventoryRepo.Hold when any night of the stay is sold out.
+var ErrNoAvailability = errors.New("room type not available for the requested dates")
+
 type InventoryRepo interface {
 	Hold(roomTypeID int, checkIn, checkOut time.Time, quantity int) error
 	Release(roomTypeID int, checkIn, checkOut time.Time, quantity int) error
-	Price(roomTypeID int, d time.Time) (int64, error)
+	// Quote returns the price of each night in [checkIn, checkOut) for one room.
+	Quote(roomTypeID int, checkIn, checkOut time.Time) ([]NightlyRate, error)
+}
+
+// NightlyRate is the catalog price of one night.
+type NightlyRate struct {
+	Date  time.Time
+	Price int64
 }
 
 type BookingRepo interface {
 	Create(ctx context.Context, b *Booking) error
 	UpdateStatus(ctx context.Context, bookingID string, status Status) error
+	// UpdateStatusFrom changes status only if the booking is still in status from; it reports whether a row changed.
+	UpdateStatusFrom(ctx context.Context, bookingID string, from, to Status) (bool, error)
+	ListOverdueUnpaid(ctx context.Context, now time.Time, limit int) ([]Booking, error)
 	GetByID(ctx context.Context, bookingID string) (*Booking, error)
+	GetByCode(ctx context.Context, code string) (*Booking, error)
 	ListByUser(ctx context.Context, userID string) ([]Booking, error)
 	Delete(ctx context.Context, bookingID string) error
 }
 
 type PaymentGateway interface {
 	RequestPayment(ctx context.Context, bookingID string, amount int64, userEmail string) error
-	RefundPayment(ctx context.Context, bookingID string, amount int64, reason string) error
+	// RefundPayment is idempotent per reference: retrying it does not refund twice.
+	RefundPayment(ctx context.Context, bookingID string, amount int64, reason, reference string) error
+}
+
+// PaymentNotifier informs the payment service about booking-side lifecycle events.
+type PaymentNotifier interface {
+	ExpirePayment(ctx context.Context, bookingID string) error
 }
@@ -0,0 +1,101 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type TaxType string
+
+const (
+	TaxPercent TaxType = "PERCENT"
+	TaxFixed   TaxType = "FIXED"
+)
+
+// TaxRule configures a tax or service charge applied to every room-night it matches.
+//
+// Percent rules use Percent; fixed rules charge Amount per room per night.
+// Inclusive rules are already part of the catalog price and are only itemized;
+// exclusive rules are added on top. Compound exclusive rules are computed on the
+// night price plus the exclusive lines before them (e.g. PB1 on room + service charge).
+type TaxRule struct {
+	Code          string     `json:"code"`
+	Name          string     `json:"name"`
+	Type          TaxType    `json:"type"`
+	Percent       float64    `json:"percent"`
+	Amount        int64      `json:"amount"`
+	Inclusive     bool       `json:"inclusive"`
+	Compound      bool       `json:"compound"`
+	RoomTypeIDs   []int      `json:"room_type_ids"`
+	EffectiveFrom *time.Time `json:"effective_from"`
+	EffectiveTo   *time.Time `json:"effective_to"`
+}
+
+// Validate checks that the rule is well-formed.
+func (r TaxRule) Validate() error {
+	if r.Code == "" {
+		return errors.New("tax rule code is required")
+	}
+	switch r.Type {
+	case TaxPercent:
+		if r.Percent <= 0 {
+			return fmt.Errorf("tax rule %s: percent must be positive", r.Code)
+		}
+	case TaxFixed:
+		if r.Amount <= 0 {
+			return fmt.Errorf("tax rule %s: amount must be positive", r.Code)
+		}
+	default:
+		return fmt.Errorf("tax rule %s: unknown type %q", r.Code, r.Type)
+	}
+	if r.Inclusive && r.Compound {
+		return fmt.Errorf("tax rule %s: inclusive rules cannot compound", r.Code)
+	}
+	if r.EffectiveFrom != nil && r.EffectiveTo != nil && !r.EffectiveTo.After(*r.EffectiveFrom) {
+		return fmt.Errorf("tax rule %s: effective_to must be after effective_from", r.Code)
+	}
+	return nil
+}
+
+// Applies reports whether the rule covers the given room type and night.
+// EffectiveFrom is inclusive and EffectiveTo exclusive.
+func (r TaxRule) Applies(roomTypeID int, night time.Time) bool {
+	if r.EffectiveFrom != nil && night.Before(*r.EffectiveFrom) {
+		return false
+	}
+	if r.EffectiveTo != nil && !night.Before(*r.EffectiveTo) {
+		return false
+	}
+	if len(r.RoomTypeIDs) == 0 {
+		return true
+	}
+	for _, id := range r.RoomTypeIDs {
+		if id == roomTypeID {
+			return true
+		}
+	}
+	return false
+}
+
+// BookingTax is an itemized tax line persisted with a booking.
+type BookingTax struct {
+	ID        string  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	BookingID string  `gorm:"index" json:"booking_id"`
+	Code      string  `gorm:"size:50" json:"code"`
+	Name      string  `gorm:"size:150" json:"name"`
+	Type      TaxType `gorm:"size:20" json:"type"`
+	Percent   float64 `json:"percent,omitempty"`
+	Inclusive bool    `json:"inclusive"`
+	Amount    int64   `json:"amount"`
+}
+
+func (bt *BookingTax) BeforeCreate(_ *gorm.DB) error {
+	if bt.ID == "" {
+		bt.ID = uuid.New().String()
+	}
+	return nil
+}
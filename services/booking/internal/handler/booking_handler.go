@@ -8,6 +8,8 @@ import (
 	"net/http"
 	"time"
 
+	"pkg/authx"
+	"pkg/hmacx"
 	"pkg/httpx"
 	"pkg/jwtx"
 
@@ -16,12 +18,36 @@ import (
 )
 
 type Handler struct {
-	svc *service.Service
-	tm  *jwtx.TokenManager
+	svc      *service.Service
+	tm       *jwtx.TokenManager
+	internal *hmacx.Verifier
+
+	requireVerifiedEmail bool
+	guestTokens          *jwtx.TokenManager
+	guestRateLimit       int
+}
+
+func NewHandler(s *service.Service, tm *jwtx.TokenManager, internal *hmacx.Verifier) *Handler {
+	return &Handler{svc: s, tm: tm, internal: internal, guestRateLimit: DefaultGuestRateLimit}
+}
+
+// SetRequireVerifiedEmail makes POST /bookings refuse users whose email is not verified.
+// It must be called before BindRoutes.
+func (h *Handler) SetRequireVerifiedEmail(required bool) {
+	h.requireVerifiedEmail = required
 }
 
-func NewHandler(s *service.Service, tm *jwtx.TokenManager) *Handler {
-	return &Handler{svc: s, tm: tm}
+// SetGuestTokens enables guest bookings without an account. tm signs and verifies the
+// booking-scoped guest tokens and must use a different secret than account tokens.
+// It must be called before BindRoutes.
+func (h *Handler) SetGuestTokens(tm *jwtx.TokenManager) {
+	h.guestTokens = tm
+}
+
+// SetGuestRateLimit sets how many guest bookings, and separately how many guest lookups,
+// a client IP may send per minute. It must be called before BindRoutes.
+func (h *Handler) SetGuestRateLimit(perMinute int) {
+	h.guestRateLimit = perMinute
 }
 
 type CreateRequest struct {
@@ -60,6 +86,10 @@ func (h *Handler) PostBooking(c *gin.Context) {
 	})
 
 	if err != nil {
+		if errors.Is(err, entity.ErrNoAvailability) {
+			c.JSON(http.StatusConflict, httpx.ErrorResponse{Error: err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, httpx.ErrorResponse{Error: err.Error()})
 		return
 	}
@@ -117,6 +147,10 @@ func (h *Handler) PostRefund(c *gin.Context) {
 		}
 	}
 
+	if !h.authorizeBooking(c, bookingID) {
+		return
+	}
+
 	booking, err := h.svc.Refund(c.Request.Context(), bookingID, req.Reason)
 	if err != nil {
 		switch {
@@ -124,6 +158,8 @@ func (h *Handler) PostRefund(c *gin.Context) {
 			c.JSON(http.StatusNotFound, httpx.ErrorResponse{Error: "booking not found"})
 		case errors.Is(err, service.ErrBookingNotPaid), errors.Is(err, service.ErrBookingAlreadyHandled):
 			c.JSON(http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, service.ErrRefundFailed):
+			c.JSON(http.StatusBadGateway, httpx.ErrorResponse{Error: err.Error()})
 		default:
 			c.JSON(http.StatusInternalServerError, httpx.ErrorResponse{Error: err.Error()})
 		}
@@ -163,93 +199,89 @@ func (h *Handler) PostInternalUpdateStatus(c *gin.Context) {
 			c.JSON(http.StatusNotFound, httpx.ErrorResponse{Error: "booking not found"})
 			return
 		}
+		if errors.Is(err, service.ErrBookingAlreadyHandled) {
+			c.JSON(http.StatusConflict, httpx.ErrorResponse{Error: err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, httpx.ErrorResponse{Error: err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, httpx.OK(gin.H{"status": st}))
 }
 
-// getUserID extracts user id from Authorization bearer token.
-func (h *Handler) getUserID(c *gin.Context) (string, error) {
-	if h.tm == nil {
-		return "", errors.New("auth not configured")
-	}
-	tok, err := jwtx.ExtractToken(c.Request)
+// GetInternalBooking returns a booking for other services, e.g. Payment checking
+// ownership and status before opening a payment.
+func (h *Handler) GetInternalBooking(c *gin.Context) {
+	booking, err := h.svc.GetByID(c.Request.Context(), c.Param("id"))
 	if err != nil {
-		return "", err
-	}
-	claims, err := h.tm.VerifyToken(tok)
-	if err != nil {
-		return "", err
-	}
-	if claims == nil || claims.UserID == "" {
-		return "", errors.New("invalid token claims")
-	}
-	return claims.UserID, nil
-}
-
-// authMiddleware ensures a valid bearer token and sets user_id in context.
-func (h *Handler) authMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		claims, err := h.getClaims(c)
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, httpx.ErrorResponse{Error: "unauthorized"})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, httpx.ErrorResponse{Error: "booking not found"})
 			return
 		}
-		c.Set("user_id", claims.UserID)
-		if claims.Email != "" {
-			c.Set("email", claims.Email)
-		}
-		c.Next()
+		c.JSON(http.StatusInternalServerError, httpx.ErrorResponse{Error: err.Error()})
+		return
 	}
+	c.JSON(http.StatusOK, httpx.OK(booking))
 }
 
-// getClaims extracts full claims from Authorization bearer token.
-func (h *Handler) getClaims(c *gin.Context) (*jwtx.AccessClaims, error) {
-	if h.tm == nil {
-		return nil, errors.New("auth not configured")
-	}
-	tok, err := jwtx.ExtractToken(c.Request)
-	if err != nil {
-		return nil, err
+// GetInternalUserBookings lists a user's bookings for other services, e.g. the auth
+// service's personal data export.
+func (h *Handler) GetInternalUserBookings(c *gin.Context) {
+	userID := c.Query("user_id")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, httpx.ErrorResponse{Error: "user_id is required"})
+		return
 	}
-	claims, err := h.tm.VerifyToken(tok)
+	list, err := h.svc.ListMine(c.Request.Context(), userID)
 	if err != nil {
-		return nil, err
-	}
-	if claims == nil || claims.UserID == "" {
-		return nil, errors.New("invalid token claims")
+		c.JSON(http.StatusInternalServerError, httpx.ErrorResponse{Error: err.Error()})
+		return
 	}
-	return claims, nil
+	c.JSON(http.StatusOK, httpx.OK(list))
 }
 
-// requireUser retrieves user_id from context (populated by authMiddleware) or parses token as fallback.
+// requireUser retrieves user_id from the claims populated by authx.Authenticate.
 func (h *Handler) requireUser(c *gin.Context) (string, error) {
-	if v, ok := c.Get("user_id"); ok {
-		if s, ok := v.(string); ok && s != "" {
-			return s, nil
-		}
-	}
-	claims, err := h.getClaims(c)
-	if err != nil {
-		return "", err
+	claims := authx.Claims(c)
+	if claims == nil || claims.UserID == "" {
+		return "", errors.New("invalid token claims")
 	}
 	return claims.UserID, nil
 }
 
-// userEmail returns email from context or token claims.
+// userEmail returns email from token claims.
 func (h *Handler) userEmail(c *gin.Context) string {
-	if v, ok := c.Get("email"); ok {
-		if s, ok := v.(string); ok && s != "" {
-			return s
-		}
-	}
-	if claims, err := h.getClaims(c); err == nil && claims.Email != "" {
+	if claims := authx.Claims(c); claims != nil {
 		return claims.Email
 	}
 	return ""
 }
 
+// authorizeBooking lets staff through and otherwise checks that the caller owns the booking.
+// It writes the error response and returns false when access is denied.
+func (h *Handler) authorizeBooking(c *gin.Context, bookingID string) bool {
+	if authx.Can(c, authx.PermBookingsManage) {
+		return true
+	}
+	userID, err := h.requireUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, httpx.ErrorResponse{Error: "unauthorized"})
+		return false
+	}
+	if _, err := h.svc.GetMineByID(c.Request.Context(), bookingID, userID); err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, httpx.ErrorResponse{Error: "booking not found"})
+		case errors.Is(err, service.ErrForbidden):
+			c.JSON(http.StatusForbidden, httpx.ErrorResponse{Error: "forbidden"})
+		default:
+			c.JSON(http.StatusInternalServerError, httpx.ErrorResponse{Error: err.Error()})
+		}
+		return false
+	}
+	return true
+}
+
 // GetMyBookings lists bookings owned by the logged-in user.
 func (h *Handler) GetMyBookings(c *gin.Context) {
 	userID, err := h.requireUser(c)
@@ -265,24 +297,19 @@ func (h *Handler) GetMyBookings(c *gin.Context) {
 	c.JSON(http.StatusOK, httpx.OK(list))
 }
 
-// GetBookingDetail returns a user's booking detail by ID.
+// GetBookingDetail returns a user's booking detail by ID. Staff may view any booking.
 func (h *Handler) GetBookingDetail(c *gin.Context) {
-	userID, err := h.requireUser(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, httpx.ErrorResponse{Error: "unauthorized"})
+	id := c.Param("id")
+	if !h.authorizeBooking(c, id) {
 		return
 	}
-	id := c.Param("id")
-	booking, err := h.svc.GetMineByID(c.Request.Context(), id, userID)
+	booking, err := h.svc.GetByID(c.Request.Context(), id)
 	if err != nil {
-		switch {
-		case errors.Is(err, gorm.ErrRecordNotFound):
+		if errors.Is(err, gorm.ErrRecordNotFound) {
 			c.JSON(http.StatusNotFound, httpx.ErrorResponse{Error: "booking not found"})
-		case err.Error() == "forbidden":
-			c.JSON(http.StatusForbidden, httpx.ErrorResponse{Error: "forbidden"})
-		default:
-			c.JSON(http.StatusInternalServerError, httpx.ErrorResponse{Error: err.Error()})
+			return
 		}
+		c.JSON(http.StatusInternalServerError, httpx.ErrorResponse{Error: err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, httpx.OK(booking))
@@ -300,8 +327,10 @@ func (h *Handler) DeleteBooking(c *gin.Context) {
 		switch {
 		case errors.Is(err, gorm.ErrRecordNotFound):
 			c.JSON(http.StatusNotFound, httpx.ErrorResponse{Error: "booking not found"})
-		case err.Error() == "forbidden":
+		case errors.Is(err, service.ErrForbidden):
 			c.JSON(http.StatusForbidden, httpx.ErrorResponse{Error: "forbidden"})
+		case errors.Is(err, service.ErrBookingNotDeletable), errors.Is(err, service.ErrBookingAlreadyHandled):
+			c.JSON(http.StatusConflict, httpx.ErrorResponse{Error: err.Error()})
 		default:
 			c.JSON(http.StatusInternalServerError, httpx.ErrorResponse{Error: err.Error()})
 		}
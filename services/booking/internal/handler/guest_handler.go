@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"booking/internal/entity"
+	"booking/internal/service"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"pkg/httpx"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type guestCreateRequest struct {
+	CheckIn  time.Time                  `json:"check_in" binding:"required"`
+	CheckOut time.Time                  `json:"check_out" binding:"required"`
+	Guests   int                        `json:"guests"`
+	FullName string                     `json:"full_name" binding:"required"`
+	Email    string                     `json:"email" binding:"required,email"`
+	Phone    string                     `json:"phone" binding:"required,max=50"`
+	Items    []entity.CreateBookingItem `json:"items" binding:"required,min=1,dive"`
+}
+
+type guestLookupRequest struct {
+	Code  string `json:"code" binding:"required"`
+	Email string `json:"email" binding:"required,email"`
+}
+
+// guestAccess is returned to guests together with a token scoped to their booking.
+type guestAccess struct {
+	Booking     *entity.Booking `json:"booking"`
+	AccessToken string          `json:"access_token"`
+	TokenType   string          `json:"token_type"`
+	ExpiresIn   int64           `json:"expires_in"`
+}
+
+// PostGuestBooking creates a booking for a guest without an account.
+func (h *Handler) PostGuestBooking(c *gin.Context) {
+	var req guestCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+		return
+	}
+	b, err := h.svc.Create(c.Request.Context(), entity.CreateBookingInput{
+		CheckIn:  req.CheckIn,
+		CheckOut: req.CheckOut,
+		Guests:   req.Guests,
+		FullName: req.FullName,
+		Email:    req.Email,
+		Phone:    req.Phone,
+		Items:    req.Items,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, entity.ErrNoAvailability):
+			c.JSON(http.StatusConflict, httpx.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, service.ErrGuestContactRequired):
+			c.JSON(http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, httpx.ErrorResponse{Error: err.Error()})
+		}
+		return
+	}
+	h.respondGuestAccess(c, http.StatusCreated, b)
+}
+
+// PostGuestLookup exchanges a booking code and the guest's email for a booking-scoped token.
+func (h *Handler) PostGuestLookup(c *gin.Context) {
+	var req guestLookupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+		return
+	}
+	b, err := h.svc.FindGuestBooking(c.Request.Context(), req.Code, req.Email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, httpx.ErrorResponse{Error: "booking not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, httpx.ErrorResponse{Error: err.Error()})
+		return
+	}
+	h.respondGuestAccess(c, http.StatusOK, b)
+}
+
+// GetGuestBooking returns the booking a guest token is scoped to.
+func (h *Handler) GetGuestBooking(c *gin.Context) {
+	booking, err := h.svc.GetByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, httpx.ErrorResponse{Error: "booking not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, httpx.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, httpx.OK(booking))
+}
+
+// PostGuestCancel cancels an unpaid guest booking or refunds a paid one.
+func (h *Handler) PostGuestCancel(c *gin.Context) {
+	var req refundRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+		return
+	}
+	booking, err := h.svc.Cancel(c.Request.Context(), c.Param("id"), req.Reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, httpx.ErrorResponse{Error: "booking not found"})
+		case errors.Is(err, service.ErrBookingAlreadyHandled):
+			c.JSON(http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, service.ErrRefundFailed):
+			c.JSON(http.StatusBadGateway, httpx.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, httpx.ErrorResponse{Error: err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, httpx.OK(booking))
+}
+
+func (h *Handler) respondGuestAccess(c *gin.Context, status int, b *entity.Booking) {
+	token, err := h.guestTokens.SignGuestToken(b.ID, b.GuestEmail)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, httpx.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(status, httpx.OK(guestAccess{
+		Booking:     b,
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(h.guestTokens.AccessTTL.Seconds()),
+	}))
+}
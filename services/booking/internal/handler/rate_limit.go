@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"pkg/httpx"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultGuestRateLimit is how many guest bookings or lookups a client IP may send per
+// minute when not configured.
+const DefaultGuestRateLimit = 10
+
+// ipRateLimiter allows up to limit requests per client IP in each fixed window. All
+// counters are reset together when a window ends, so memory stays bounded by the
+// number of clients seen in one window.
+type ipRateLimiter struct {
+	limit  int
+	window time.Duration
+
+	mu     sync.Mutex
+	start  time.Time
+	counts map[string]int
+}
+
+func newIPRateLimiter(limit int, window time.Duration) *ipRateLimiter {
+	return &ipRateLimiter{limit: limit, window: window, counts: make(map[string]int)}
+}
+
+// allow counts a request from ip and reports whether it is within the limit, and
+// otherwise how long until the window resets.
+func (l *ipRateLimiter) allow(ip string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.start) >= l.window {
+		l.start = now
+		clear(l.counts)
+	}
+	if l.counts[ip] >= l.limit {
+		return false, l.start.Add(l.window).Sub(now)
+	}
+	l.counts[ip]++
+	return true, 0
+}
+
+// middleware answers 429 with Retry-After once the client IP is over the limit.
+func (l *ipRateLimiter) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ok, wait := l.allow(c.ClientIP(), time.Now())
+		if !ok {
+			c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, httpx.ErrorResponse{Error: "too many requests"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -1,22 +1,51 @@
 package handler
 
-import "github.com/gin-gonic/gin"
+import (
+	"time"
+
+	"pkg/authx"
+
+	"github.com/gin-gonic/gin"
+)
 
 // BindRoutes attaches booking endpoints to router.
 func (h *Handler) BindRoutes(r *gin.Engine) {
 	booking := r.Group("/bookings")
-	booking.Use(h.authMiddleware())
+	booking.Use(authx.Authenticate(h.tm))
+	create := []gin.HandlerFunc{h.PostBooking}
+	if h.requireVerifiedEmail {
+		create = append([]gin.HandlerFunc{authx.RequireVerifiedEmail()}, create...)
+	}
 	{
+		// self-service: guests only reach their own bookings, staff may act on any
 		booking.GET("", h.GetMyBookings)
-		booking.POST("", h.PostBooking)
+		booking.POST("", create...)
 		booking.GET("/:id", h.GetBookingDetail)
 		booking.DELETE("/:id", h.DeleteBooking)
-		booking.POST("/:id/checkin", h.PostCheckIn)
-		booking.POST("/:id/checkout", h.PostCheckOut)
 		booking.POST("/:id/refund", h.PostRefund)
+
+		// front desk
+		frontDesk := booking.Group("", authx.RequirePermission(authx.PermBookingsFrontDesk))
+		frontDesk.POST("/:id/checkin", h.PostCheckIn)
+		frontDesk.POST("/:id/checkout", h.PostCheckOut)
 	}
+	if h.guestTokens != nil {
+		guest := r.Group("/guest/bookings")
+		// unauthenticated: bound booking spam and guessing booking codes by email
+		guest.POST("", newIPRateLimiter(h.guestRateLimit, time.Minute).middleware(), h.PostGuestBooking)
+		guest.POST("/lookup", newIPRateLimiter(h.guestRateLimit, time.Minute).middleware(), h.PostGuestLookup)
+
+		// a guest token only opens the booking it was issued for
+		scoped := guest.Group("/:id", authx.AuthenticateGuest(h.guestTokens, "id"))
+		scoped.GET("", h.GetGuestBooking)
+		scoped.POST("/cancel", h.PostGuestCancel)
+	}
+
 	internal := r.Group("/internal/bookings")
+	internal.Use(authx.Internal(h.internal))
 	{
+		internal.GET("", h.GetInternalUserBookings)
+		internal.GET("/:id", h.GetInternalBooking)
 		internal.POST(":id/status", h.PostInternalUpdateStatus)
 	}
 }
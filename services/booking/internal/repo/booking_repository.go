@@ -3,6 +3,7 @@ package repo
 import (
 	"booking/internal/entity"
 	"context"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -17,12 +18,23 @@ func NewBookingRepository(db *gorm.DB) *BookingRepository {
 
 func (r *BookingRepository) Create(ctx context.Context, b *entity.Booking) error {
 	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		// Detach items to avoid GORM auto-saving associations
+		// Detach items and tax lines to avoid GORM auto-saving associations
 		items := b.Items
+		taxLines := b.TaxLines
 		b.Items = nil
+		b.TaxLines = nil
 		if err := tx.Create(b).Error; err != nil {
 			return err
 		}
+		if len(taxLines) > 0 {
+			for i := range taxLines {
+				taxLines[i].BookingID = b.ID
+			}
+			if err := tx.Create(&taxLines).Error; err != nil {
+				return err
+			}
+			b.TaxLines = taxLines
+		}
 		// Ensure BookingID is set on items (if not set by caller)
 		if len(items) > 0 {
 			for i := range items {
@@ -41,7 +53,8 @@ func (r *BookingRepository) Create(ctx context.Context, b *entity.Booking) error
 func (r *BookingRepository) ListByUser(ctx context.Context, userID string) ([]entity.Booking, error) {
 	var list []entity.Booking
 	if err := r.db.WithContext(ctx).
-		Preload("Items").
+		Preload("Items.NightlyRates").
+		Preload("TaxLines").
 		Where("user_id = ?", userID).
 		Order("created_at DESC").
 		Find(&list).Error; err != nil {
@@ -54,22 +67,66 @@ func (r *BookingRepository) UpdateStatus(ctx context.Context, id string, status
 	return r.db.WithContext(ctx).Model(&entity.Booking{}).Where("id = ?", id).Update("status", status).Error
 }
 
+func (r *BookingRepository) UpdateStatusFrom(ctx context.Context, id string, from, to entity.Status) (bool, error) {
+	res := r.db.WithContext(ctx).
+		Model(&entity.Booking{}).
+		Where("id = ? AND status = ?", id, from).
+		Update("status", to)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// ListOverdueUnpaid returns UNPAID bookings whose payment deadline has passed, oldest first.
+func (r *BookingRepository) ListOverdueUnpaid(ctx context.Context, now time.Time, limit int) ([]entity.Booking, error) {
+	var list []entity.Booking
+	if err := r.db.WithContext(ctx).
+		Preload("Items.NightlyRates").
+		Where("status = ? AND payment_due_at IS NOT NULL AND payment_due_at <= ?", entity.StatusUnpaid, now).
+		Order("payment_due_at ASC").
+		Limit(limit).
+		Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
 func (r *BookingRepository) GetByID(ctx context.Context, id string) (*entity.Booking, error) {
 	var b entity.Booking
 	if err := r.db.WithContext(ctx).
-		Preload("Items").
+		Preload("Items.NightlyRates").
+		Preload("TaxLines").
 		First(&b, "id = ?", id).Error; err != nil {
 		return nil, err
 	}
 	return &b, nil
 }
 
+func (r *BookingRepository) GetByCode(ctx context.Context, code string) (*entity.Booking, error) {
+	var b entity.Booking
+	if err := r.db.WithContext(ctx).
+		Preload("Items.NightlyRates").
+		Preload("TaxLines").
+		First(&b, "code = ?", code).Error; err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
 func (r *BookingRepository) Delete(ctx context.Context, id string) error {
 	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		// delete items first
+		// delete nightly rates and items first
+		itemIDs := tx.Model(&entity.BookingItem{}).Select("id").Where("booking_id = ?", id)
+		if err := tx.Where("booking_item_id IN (?)", itemIDs).Delete(&entity.BookingNightRate{}).Error; err != nil {
+			return err
+		}
 		if err := tx.Where("booking_id = ?", id).Delete(&entity.BookingItem{}).Error; err != nil {
 			return err
 		}
+		if err := tx.Where("booking_id = ?", id).Delete(&entity.BookingTax{}).Error; err != nil {
+			return err
+		}
 		res := tx.Delete(&entity.Booking{}, "id = ?", id)
 		if res.Error != nil {
 			return res.Error
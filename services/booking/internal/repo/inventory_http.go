@@ -2,61 +2,124 @@ package repo
 
 import (
 	"booking/internal/entity"
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 	"time"
+
+	"pkg/hmacx"
 )
 
 type InventoryHTTP struct {
 	base   string
 	client *http.Client
+	signer *hmacx.Signer
 }
 
-func NewInventoryHTTPRepo(baseURL string) entity.InventoryRepo {
+func NewInventoryHTTPRepo(baseURL string, signer *hmacx.Signer) entity.InventoryRepo {
 	return &InventoryHTTP{
 		base:   baseURL,
 		client: &http.Client{Timeout: 5 * time.Second},
+		signer: signer,
 	}
 }
 
-func (r *InventoryHTTP) Hold(roomTypeID int, from, to time.Time, qty int) error    { return nil }
-func (r *InventoryHTTP) Release(roomTypeID int, from, to time.Time, qty int) error { return nil }
+type inventoryHoldRequest struct {
+	RoomTypeID int    `json:"room_type_id"`
+	CheckIn    string `json:"check_in"`
+	CheckOut   string `json:"check_out"`
+	Quantity   int    `json:"quantity"`
+}
+
+// Hold decrements catalog stock for every night in [from, to).
+func (r *InventoryHTTP) Hold(roomTypeID int, from, to time.Time, qty int) error {
+	return r.postInventory("hold", roomTypeID, from, to, qty)
+}
+
+// Release returns catalog stock for every night in [from, to).
+func (r *InventoryHTTP) Release(roomTypeID int, from, to time.Time, qty int) error {
+	return r.postInventory("release", roomTypeID, from, to, qty)
+}
+
+func (r *InventoryHTTP) postInventory(action string, roomTypeID int, from, to time.Time, qty int) error {
+	payload := inventoryHoldRequest{
+		RoomTypeID: roomTypeID,
+		CheckIn:    from.Format("2006-01-02"),
+		CheckOut:   to.Format("2006-01-02"),
+		Quantity:   qty,
+	}
+	body, _ := json.Marshal(payload)
+	u := fmt.Sprintf("%s/internal/inventory/%s", r.base, action)
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := r.signer.Sign(req, body); err != nil {
+		return err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusConflict {
+		return entity.ErrNoAvailability
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("catalog inventory %s failed: %s", action, resp.Status)
+	}
+	return nil
+}
 
-type catalogAvailabilityItem struct {
-	RoomTypeID    int   `json:"room_type_id"`
-	PricePerNight int64 `json:"price_per_night"`
+type catalogNightlyRate struct {
+	Date  string `json:"date"`
+	Price int64  `json:"price"`
 }
-type catalogAvailabilityResp struct {
-	Data []catalogAvailabilityItem `json:"data"`
+type catalogQuoteResp struct {
+	Data struct {
+		RoomTypeID int                  `json:"room_type_id"`
+		Nights     []catalogNightlyRate `json:"nights"`
+		Total      int64                `json:"total"`
+	} `json:"data"`
 }
 
-func (r *InventoryHTTP) Price(roomTypeID int, d time.Time) (int64, error) {
-	// Query one-day range [d, d+1)
+// Quote fetches the per-night price breakdown for [checkIn, checkOut).
+func (r *InventoryHTTP) Quote(roomTypeID int, checkIn, checkOut time.Time) ([]entity.NightlyRate, error) {
 	q := url.Values{}
-	q.Set("check_in", d.Format("2006-01-02"))
-	q.Set("check_out", d.AddDate(0, 0, 1).Format("2006-01-02"))
-	u := fmt.Sprintf("%s/catalog/availability?%s", r.base, q.Encode())
+	q.Set("room_type_id", strconv.Itoa(roomTypeID))
+	q.Set("check_in", checkIn.Format("2006-01-02"))
+	q.Set("check_out", checkOut.Format("2006-01-02"))
+	u := fmt.Sprintf("%s/catalog/quote?%s", r.base, q.Encode())
 
 	req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, u, nil)
 	resp, err := r.client.Do(req)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("room_type_id %d not found", roomTypeID)
+	}
 	if resp.StatusCode != http.StatusOK {
-		return 0, fmt.Errorf("catalog returned %d", resp.StatusCode)
+		return nil, fmt.Errorf("catalog returned %d", resp.StatusCode)
 	}
-	var out catalogAvailabilityResp
+	var out catalogQuoteResp
 	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
-		return 0, err
+		return nil, err
 	}
-	for _, it := range out.Data {
-		if it.RoomTypeID == roomTypeID {
-			return it.PricePerNight, nil
+	rates := make([]entity.NightlyRate, 0, len(out.Data.Nights))
+	for _, n := range out.Data.Nights {
+		d, err := time.Parse("2006-01-02", n.Date)
+		if err != nil {
+			return nil, err
 		}
+		rates = append(rates, entity.NightlyRate{Date: d, Price: n.Price})
 	}
-	return 0, fmt.Errorf("room_type_id %d not found", roomTypeID)
+	return rates, nil
 }
@@ -0,0 +1,87 @@
+package repo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"pkg/hmacx"
+)
+
+// PaymentHTTP talks to the payment service's internal endpoints. It implements both
+// entity.PaymentGateway and entity.PaymentNotifier.
+type PaymentHTTP struct {
+	base   string
+	client *http.Client
+	signer *hmacx.Signer
+}
+
+func NewPaymentHTTPClient(baseURL string, signer *hmacx.Signer) *PaymentHTTP {
+	if baseURL == "" {
+		baseURL = "http://payment:8004"
+	}
+	return &PaymentHTTP{
+		base:   baseURL,
+		client: &http.Client{Timeout: 5 * time.Second},
+		signer: signer,
+	}
+}
+
+// RequestPayment opens a payment attempt for a new booking.
+func (p *PaymentHTTP) RequestPayment(ctx context.Context, bookingID string, amount int64, userEmail string) error {
+	return p.post(ctx, "/internal/payments/intents", map[string]any{
+		"booking_id":     bookingID,
+		"amount":         amount,
+		"customer_email": userEmail,
+	})
+}
+
+// RefundPayment refunds up to amount of the booking's settled payments.
+func (p *PaymentHTTP) RefundPayment(ctx context.Context, bookingID string, amount int64, reason, reference string) error {
+	return p.post(ctx, "/internal/payments/refunds", map[string]any{
+		"booking_id": bookingID,
+		"amount":     amount,
+		"reason":     reason,
+		"reference":  reference,
+	})
+}
+
+// ExpirePayment asks the payment service to expire pending payments of a booking.
+func (p *PaymentHTTP) ExpirePayment(ctx context.Context, bookingID string) error {
+	return p.post(ctx, "/internal/payments/expire", map[string]any{"booking_id": bookingID})
+}
+
+// post sends a signed JSON request and turns non-2xx answers into errors carrying
+// the payment service's message.
+func (p *PaymentHTTP) post(ctx context.Context, path string, payload map[string]any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.base+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := p.signer.Sign(req, body); err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&e) == nil && e.Error != "" {
+			return fmt.Errorf("payment %s failed: %s: %s", path, resp.Status, e.Error)
+		}
+		return fmt.Errorf("payment %s failed: %s", path, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package repo
+
+import (
+	"booking/internal/entity"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+type taxRuleFile struct {
+	Code          string  `json:"code"`
+	Name          string  `json:"name"`
+	Type          string  `json:"type"`
+	Percent       float64 `json:"percent"`
+	Amount        int64   `json:"amount"`
+	Inclusive     bool    `json:"inclusive"`
+	Compound      bool    `json:"compound"`
+	RoomTypeIDs   []int   `json:"room_type_ids"`
+	EffectiveFrom string  `json:"effective_from"` // YYYY-MM-DD, inclusive
+	EffectiveTo   string  `json:"effective_to"`   // YYYY-MM-DD, exclusive
+}
+
+// LoadTaxRules reads an ordered tax rule set from a JSON array file.
+func LoadTaxRules(path string) ([]entity.TaxRule, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var in []taxRuleFile
+	if err := json.Unmarshal(raw, &in); err != nil {
+		return nil, fmt.Errorf("parse tax rules: %w", err)
+	}
+
+	rules := make([]entity.TaxRule, 0, len(in))
+	for _, r := range in {
+		rule := entity.TaxRule{
+			Code:        r.Code,
+			Name:        r.Name,
+			Type:        entity.TaxType(r.Type),
+			Percent:     r.Percent,
+			Amount:      r.Amount,
+			Inclusive:   r.Inclusive,
+			Compound:    r.Compound,
+			RoomTypeIDs: r.RoomTypeIDs,
+		}
+		if rule.EffectiveFrom, err = parseRuleDate(r.EffectiveFrom); err != nil {
+			return nil, fmt.Errorf("tax rule %s: %w", r.Code, err)
+		}
+		if rule.EffectiveTo, err = parseRuleDate(r.EffectiveTo); err != nil {
+			return nil, fmt.Errorf("tax rule %s: %w", r.Code, err)
+		}
+		if err := rule.Validate(); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func parseRuleDate(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	d, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
@@ -4,13 +4,22 @@ import (
 	"booking/internal/entity"
 	"context"
 	"errors"
+	"fmt"
+	"log"
+	"strings"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type Service struct {
-	inv  entity.InventoryRepo
-	repo entity.BookingRepo
-	pay  entity.PaymentGateway
+	inv        entity.InventoryRepo
+	repo       entity.BookingRepo
+	pay        entity.PaymentGateway
+	notify     entity.PaymentNotifier
+	taxRules   []entity.TaxRule
+	paymentTTL time.Duration
+	clock      func() time.Time
 }
 
 var (
@@ -20,13 +29,37 @@ var (
 	ErrBookingAlreadyHandled = errors.New("booking already handled")
 	// ErrBookingNotCheckedIn is returned when trying to checkout before check-in.
 	ErrBookingNotCheckedIn = errors.New("booking is not checked-in")
+	// ErrForbidden is returned when a user acts on a booking they do not own.
+	ErrForbidden = errors.New("forbidden")
+	// ErrBookingNotDeletable is returned when deleting a booking that is paid or in progress.
+	ErrBookingNotDeletable = errors.New("only unpaid or cancelled bookings can be deleted")
+	// ErrRefundFailed is returned when the payment service did not refund a booking;
+	// the booking is left REFUND_PENDING.
+	ErrRefundFailed = errors.New("refund failed; the booking is pending refund")
+	// ErrGuestContactRequired is returned when a guest booking lacks a name or email.
+	ErrGuestContactRequired = errors.New("guest bookings require full_name and email")
 )
 
-func NewService(inv entity.InventoryRepo, repo entity.BookingRepo, pay entity.PaymentGateway) *Service {
+func NewService(inv entity.InventoryRepo, repo entity.BookingRepo, pay entity.PaymentGateway, notify entity.PaymentNotifier) *Service {
 	return &Service{
-		inv:  inv,
-		repo: repo,
-		pay:  pay,
+		inv:        inv,
+		repo:       repo,
+		pay:        pay,
+		notify:     notify,
+		paymentTTL: DefaultPaymentTTL,
+		clock:      time.Now,
+	}
+}
+
+// SetTaxRules replaces the ordered tax and service-charge rules applied to new bookings.
+func (s *Service) SetTaxRules(rules []entity.TaxRule) {
+	s.taxRules = rules
+}
+
+// SetPaymentTTL overrides how long new UNPAID bookings wait for payment before expiring.
+func (s *Service) SetPaymentTTL(ttl time.Duration) {
+	if ttl > 0 {
+		s.paymentTTL = ttl
 	}
 }
 
@@ -48,33 +81,53 @@ func (s *Service) Create(ctx context.Context, in entity.CreateBookingInput) (*en
 		return nil, errors.New("booking items cannot be empty")
 	}
 
+	guest := in.UserID == ""
+	email := strings.ToLower(strings.TrimSpace(in.Email))
+	if guest && (strings.TrimSpace(in.FullName) == "" || email == "") {
+		return nil, ErrGuestContactRequired
+	}
+
 	var subtotal int64
 	var items []entity.BookingItem
 
 	for _, it := range in.Items {
-		// Simplified: snapshot first-night price
-		perNight, err := s.inv.Price(it.RoomTypeID, in.CheckIn)
+		rates, err := s.inv.Quote(it.RoomTypeID, in.CheckIn, in.CheckOut)
 		if err != nil {
 			return nil, err
 		}
+		if len(rates) != nights {
+			return nil, fmt.Errorf("catalog quoted %d nights for a %d-night stay", len(rates), nights)
+		}
 
-		// optional hold (currently NO-OP implementation)
-		if err := s.inv.Hold(it.RoomTypeID, in.CheckIn, in.CheckOut, it.Quantity); err != nil {
-			return nil, err
+		var perRoom int64
+		nightly := make([]entity.BookingNightRate, 0, len(rates))
+		for _, r := range rates {
+			perRoom += r.Price
+			nightly = append(nightly, entity.BookingNightRate{Date: r.Date, Price: r.Price})
 		}
 
-		lineTotal := int64(it.Quantity) * int64(nights) * perNight
+		lineTotal := int64(it.Quantity) * perRoom
 		subtotal += lineTotal
 		items = append(items, entity.BookingItem{
 			RoomTypeID:    it.RoomTypeID,
 			Quantity:      it.Quantity,
-			PricePerNight: perNight,
+			PricePerNight: perRoom / int64(nights),
 			LineTotal:     lineTotal,
+			NightlyRates:  nightly,
 		})
 	}
 
-	taxes := int64(0)
+	// Hold stock for every item; undo earlier holds if any item is sold out.
+	for i, it := range items {
+		if err := s.inv.Hold(it.RoomTypeID, in.CheckIn, in.CheckOut, it.Quantity); err != nil {
+			s.releaseItems(items[:i], in.CheckIn, in.CheckOut)
+			return nil, err
+		}
+	}
+
+	taxLines, taxes := computeTaxes(s.taxRules, items)
 	total := subtotal + taxes
+	dueAt := s.clock().UTC().Add(s.paymentTTL)
 
 	b := &entity.Booking{
 		UserID:       in.UserID,
@@ -86,14 +139,25 @@ func (s *Service) Create(ctx context.Context, in entity.CreateBookingInput) (*en
 		Taxes:        taxes,
 		Total:        total,
 		Status:       entity.StatusUnpaid,
+		PaymentDueAt: &dueAt,
 		Items:        items,
+		TaxLines:     taxLines,
+	}
+	if guest {
+		b.GuestName = strings.TrimSpace(in.FullName)
+		b.GuestEmail = email
+		b.GuestPhone = strings.TrimSpace(in.Phone)
 	}
 
 	if err := s.repo.Create(ctx, b); err != nil {
+		s.releaseItems(items, in.CheckIn, in.CheckOut)
 		return nil, err
 	}
 
-	_ = s.pay.RequestPayment(ctx, b.ID, b.Total, in.Email)
+	// The guest can still open the payment with POST /bookings/:id/pay if this fails.
+	if err := s.pay.RequestPayment(ctx, b.ID, b.Total, in.Email); err != nil {
+		log.Printf("request payment booking_id=%s: %v", b.ID, err)
+	}
 
 	return b, nil
 }
@@ -113,14 +177,20 @@ func (s *Service) CheckIn(ctx context.Context, bookingID string) (*entity.Bookin
 		return nil, ErrBookingNotPaid
 	}
 
-	if err := s.repo.UpdateStatus(ctx, booking.ID, entity.StatusCheckedIn); err != nil {
+	changed, err := s.repo.UpdateStatusFrom(ctx, booking.ID, entity.StatusPaid, entity.StatusCheckedIn)
+	if err != nil {
 		return nil, err
 	}
+	if !changed {
+		return nil, ErrBookingAlreadyHandled
+	}
 	booking.Status = entity.StatusCheckedIn
 	return booking, nil
 }
 
-// Refund triggers refund flow via payment service and cancels the booking.
+// Refund cancels a paid booking and refunds it through the payment service. The
+// booking is REFUND_PENDING while the refund is requested and stays so if it fails,
+// so the refund can be retried; it becomes CANCELLED once the money is returned.
 func (s *Service) Refund(ctx context.Context, bookingID, reason string) (*entity.Booking, error) {
 	booking, err := s.repo.GetByID(ctx, bookingID)
 	if err != nil {
@@ -131,7 +201,7 @@ func (s *Service) Refund(ctx context.Context, bookingID, reason string) (*entity
 		return nil, ErrBookingAlreadyHandled
 	}
 
-	if booking.Status != entity.StatusPaid {
+	if booking.Status != entity.StatusPaid && booking.Status != entity.StatusRefundPending {
 		return nil, ErrBookingNotPaid
 	}
 
@@ -139,17 +209,73 @@ func (s *Service) Refund(ctx context.Context, bookingID, reason string) (*entity
 		reason = "user requested"
 	}
 
-	if err := s.pay.RefundPayment(ctx, booking.ID, booking.Total, reason); err != nil {
-		return nil, err
+	if booking.Status == entity.StatusPaid {
+		changed, err := s.repo.UpdateStatusFrom(ctx, booking.ID, entity.StatusPaid, entity.StatusRefundPending)
+		if err != nil {
+			return nil, err
+		}
+		if !changed {
+			return nil, ErrBookingAlreadyHandled
+		}
+		booking.Status = entity.StatusRefundPending
 	}
 
-	if err := s.repo.UpdateStatus(ctx, booking.ID, entity.StatusCancelled); err != nil {
+	// a booking is cancelled at most once, so its ID identifies the cancellation across retries
+	if err := s.pay.RefundPayment(ctx, booking.ID, booking.Total, reason, "cancel:"+booking.ID); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRefundFailed, err)
+	}
+
+	changed, err := s.repo.UpdateStatusFrom(ctx, booking.ID, entity.StatusRefundPending, entity.StatusCancelled)
+	if err != nil {
 		return nil, err
 	}
+	if !changed {
+		// Payment marked the booking REFUNDED in the meantime and released its stock.
+		return s.repo.GetByID(ctx, booking.ID)
+	}
 	booking.Status = entity.StatusCancelled
+	s.releaseBooking(booking)
+	return booking, nil
+}
+
+// Cancel withdraws an UNPAID booking, or refunds and cancels a PAID one.
+func (s *Service) Cancel(ctx context.Context, bookingID, reason string) (*entity.Booking, error) {
+	booking, err := s.repo.GetByID(ctx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	if booking.Status == entity.StatusPaid || booking.Status == entity.StatusRefundPending {
+		return s.Refund(ctx, bookingID, reason)
+	}
+	if booking.Status != entity.StatusUnpaid {
+		return nil, ErrBookingAlreadyHandled
+	}
+	if err := s.cancelUnpaid(ctx, booking); err != nil {
+		return nil, err
+	}
 	return booking, nil
 }
 
+// cancelUnpaid moves an UNPAID booking to CANCELLED, releases its stock and expires
+// its pending payment. It fails with ErrBookingAlreadyHandled if a payment settled first.
+func (s *Service) cancelUnpaid(ctx context.Context, booking *entity.Booking) error {
+	changed, err := s.repo.UpdateStatusFrom(ctx, booking.ID, entity.StatusUnpaid, entity.StatusCancelled)
+	if err != nil {
+		return err
+	}
+	if !changed {
+		return ErrBookingAlreadyHandled
+	}
+	booking.Status = entity.StatusCancelled
+	s.releaseBooking(booking)
+	if s.notify != nil {
+		if err := s.notify.ExpirePayment(ctx, booking.ID); err != nil {
+			log.Printf("expire payment booking_id=%s: %v", booking.ID, err)
+		}
+	}
+	return nil
+}
+
 // CheckOut marks a booking as checked-out. Requires it to be checked-in first.
 func (s *Service) CheckOut(ctx context.Context, bookingID string) (*entity.Booking, error) {
 	booking, err := s.repo.GetByID(ctx, bookingID)
@@ -165,16 +291,45 @@ func (s *Service) CheckOut(ctx context.Context, bookingID string) (*entity.Booki
 		return nil, ErrBookingNotCheckedIn
 	}
 
-	if err := s.repo.UpdateStatus(ctx, booking.ID, entity.StatusCheckedOut); err != nil {
+	changed, err := s.repo.UpdateStatusFrom(ctx, booking.ID, entity.StatusCheckedIn, entity.StatusCheckedOut)
+	if err != nil {
 		return nil, err
 	}
+	if !changed {
+		return nil, ErrBookingAlreadyHandled
+	}
 	booking.Status = entity.StatusCheckedOut
 	return booking, nil
 }
 
 // RepoUpdateStatus is an internal helper to directly set booking status via repository.
+// Moving a booking that still holds stock to CANCELLED or REFUNDED releases its inventory.
 func (s *Service) RepoUpdateStatus(ctx context.Context, bookingID string, status entity.Status) error {
-	return s.repo.UpdateStatus(ctx, bookingID, status)
+	b, err := s.repo.GetByID(ctx, bookingID)
+	if err != nil {
+		return err
+	}
+	switch {
+	// A payment settling after the booking expired must not resurrect it.
+	case status == entity.StatusPaid && b.Status != entity.StatusUnpaid:
+		return ErrBookingAlreadyHandled
+	// Only money that was taken can be refunded; a checked-in or finished stay keeps its status.
+	case status == entity.StatusRefunded && b.Status != entity.StatusPaid && b.Status != entity.StatusRefundPending:
+		return ErrBookingAlreadyHandled
+	}
+	// Only move from the status just read, so a concurrent sweeper, cancellation or
+	// webhook cannot be overwritten and stock is released exactly once.
+	changed, err := s.repo.UpdateStatusFrom(ctx, bookingID, b.Status, status)
+	if err != nil {
+		return err
+	}
+	if !changed {
+		return ErrBookingAlreadyHandled
+	}
+	if holdsInventory(b.Status) && !holdsInventory(status) {
+		s.releaseBooking(b)
+	}
+	return nil
 }
 
 // ListMine returns bookings owned by the given user.
@@ -182,6 +337,11 @@ func (s *Service) ListMine(ctx context.Context, userID string) ([]entity.Booking
 	return s.repo.ListByUser(ctx, userID)
 }
 
+// GetByID fetches a booking without ownership checks (staff views).
+func (s *Service) GetByID(ctx context.Context, bookingID string) (*entity.Booking, error) {
+	return s.repo.GetByID(ctx, bookingID)
+}
+
 // GetMineByID fetches a booking by id and ensures it belongs to the given user.
 func (s *Service) GetMineByID(ctx context.Context, bookingID, userID string) (*entity.Booking, error) {
 	b, err := s.repo.GetByID(ctx, bookingID)
@@ -189,19 +349,67 @@ func (s *Service) GetMineByID(ctx context.Context, bookingID, userID string) (*e
 		return nil, err
 	}
 	if b.UserID != userID {
-		return nil, errors.New("forbidden")
+		return nil, ErrForbidden
 	}
 	return b, nil
 }
 
-// DeleteMine deletes a booking if it belongs to the given user.
+// FindGuestBooking looks up a guest booking by its code and contact email. A wrong email
+// is reported as not found so codes cannot be probed.
+func (s *Service) FindGuestBooking(ctx context.Context, code, email string) (*entity.Booking, error) {
+	b, err := s.repo.GetByCode(ctx, strings.ToUpper(strings.TrimSpace(code)))
+	if err != nil {
+		return nil, err
+	}
+	if b.UserID != "" || b.GuestEmail == "" || !strings.EqualFold(b.GuestEmail, strings.TrimSpace(email)) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return b, nil
+}
+
+// DeleteMine deletes a booking if it belongs to the given user. Only UNPAID and
+// CANCELLED bookings can be deleted; paid stays go through Refund.
 func (s *Service) DeleteMine(ctx context.Context, bookingID, userID string) error {
 	b, err := s.repo.GetByID(ctx, bookingID)
 	if err != nil {
 		return err
 	}
 	if b.UserID != userID {
-		return errors.New("forbidden")
+		return ErrForbidden
+	}
+	switch b.Status {
+	case entity.StatusUnpaid:
+		// cancel first so a payment settling meanwhile is caught and stock released once
+		if err := s.cancelUnpaid(ctx, b); err != nil {
+			return err
+		}
+	case entity.StatusCancelled:
+	default:
+		return ErrBookingNotDeletable
 	}
 	return s.repo.Delete(ctx, bookingID)
 }
+
+// holdsInventory reports whether a booking in the given status still occupies catalog stock.
+func holdsInventory(status entity.Status) bool {
+	switch status {
+	case entity.StatusUnpaid, entity.StatusPaid, entity.StatusRefundPending, entity.StatusCheckedIn:
+		return true
+	default:
+		return false
+	}
+}
+
+// releaseBooking returns the stock held by all items of a booking.
+func (s *Service) releaseBooking(b *entity.Booking) {
+	s.releaseItems(b.Items, b.CheckInDate, b.CheckOutDate)
+}
+
+// releaseItems returns held stock; failures are logged since the booking state has already moved on.
+func (s *Service) releaseItems(items []entity.BookingItem, checkIn, checkOut time.Time) {
+	for _, it := range items {
+		if err := s.inv.Release(it.RoomTypeID, checkIn, checkOut, it.Quantity); err != nil {
+			log.Printf("release inventory room_type_id=%d: %v", it.RoomTypeID, err)
+		}
+	}
+}
@@ -0,0 +1,354 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"booking/internal/entity"
+
+	"gorm.io/gorm"
+)
+
+const nightPrice = 500_000
+
+// memInventory is an in-memory catalog with stock per room type and night.
+type memInventory struct {
+	stock map[int]map[string]int
+}
+
+func newMemInventory(roomTypes []int, from time.Time, nights, rooms int) *memInventory {
+	inv := &memInventory{stock: map[int]map[string]int{}}
+	for _, id := range roomTypes {
+		inv.stock[id] = map[string]int{}
+		for d := range nights {
+			inv.stock[id][night(from, d)] = rooms
+		}
+	}
+	return inv
+}
+
+func night(from time.Time, d int) string { return from.AddDate(0, 0, d).Format(time.DateOnly) }
+
+func (m *memInventory) Hold(roomTypeID int, checkIn, checkOut time.Time, quantity int) error {
+	for d := range daysBetween(checkIn, checkOut) {
+		if m.stock[roomTypeID][night(checkIn, d)] < quantity {
+			return entity.ErrNoAvailability
+		}
+	}
+	for d := range daysBetween(checkIn, checkOut) {
+		m.stock[roomTypeID][night(checkIn, d)] -= quantity
+	}
+	return nil
+}
+
+func (m *memInventory) Release(roomTypeID int, checkIn, checkOut time.Time, quantity int) error {
+	for d := range daysBetween(checkIn, checkOut) {
+		m.stock[roomTypeID][night(checkIn, d)] += quantity
+	}
+	return nil
+}
+
+func (m *memInventory) Quote(_ int, checkIn, checkOut time.Time) ([]entity.NightlyRate, error) {
+	var rates []entity.NightlyRate
+	for d := range daysBetween(checkIn, checkOut) {
+		rates = append(rates, entity.NightlyRate{Date: checkIn.AddDate(0, 0, d), Price: nightPrice})
+	}
+	return rates, nil
+}
+
+// memBookings is an in-memory entity.BookingRepo.
+type memBookings struct {
+	byID      map[string]*entity.Booking
+	createErr error
+	// afterList runs once the overdue bookings were listed, to race the sweeper.
+	afterList func()
+}
+
+func (m *memBookings) Create(_ context.Context, b *entity.Booking) error {
+	if m.createErr != nil {
+		return m.createErr
+	}
+	_ = b.BeforeCreate(nil)
+	cp := *b
+	m.byID[b.ID] = &cp
+	return nil
+}
+
+func (m *memBookings) UpdateStatus(_ context.Context, id string, status entity.Status) error {
+	b, ok := m.byID[id]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	b.Status = status
+	return nil
+}
+
+func (m *memBookings) UpdateStatusFrom(_ context.Context, id string, from, to entity.Status) (bool, error) {
+	b, ok := m.byID[id]
+	if !ok || b.Status != from {
+		return false, nil
+	}
+	b.Status = to
+	return true, nil
+}
+
+func (m *memBookings) ListOverdueUnpaid(_ context.Context, now time.Time, limit int) ([]entity.Booking, error) {
+	var out []entity.Booking
+	for _, b := range m.byID {
+		if b.Status == entity.StatusUnpaid && b.PaymentDueAt != nil && b.PaymentDueAt.Before(now) && len(out) < limit {
+			out = append(out, *b)
+		}
+	}
+	if m.afterList != nil {
+		m.afterList()
+	}
+	return out, nil
+}
+
+func (m *memBookings) GetByID(_ context.Context, id string) (*entity.Booking, error) {
+	b, ok := m.byID[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	cp := *b
+	return &cp, nil
+}
+
+func (m *memBookings) GetByCode(_ context.Context, code string) (*entity.Booking, error) {
+	for _, b := range m.byID {
+		if b.Code == code {
+			cp := *b
+			return &cp, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *memBookings) ListByUser(_ context.Context, userID string) ([]entity.Booking, error) {
+	var out []entity.Booking
+	for _, b := range m.byID {
+		if b.UserID == userID {
+			out = append(out, *b)
+		}
+	}
+	return out, nil
+}
+
+func (m *memBookings) Delete(_ context.Context, id string) error {
+	delete(m.byID, id)
+	return nil
+}
+
+// fakePayments records the calls made to the payment service.
+type fakePayments struct {
+	requested []string
+	refunds   []string
+	refundErr error
+}
+
+func (p *fakePayments) RequestPayment(_ context.Context, bookingID string, _ int64, _ string) error {
+	p.requested = append(p.requested, bookingID)
+	return nil
+}
+
+func (p *fakePayments) RefundPayment(_ context.Context, _ string, _ int64, _, reference string) error {
+	p.refunds = append(p.refunds, reference)
+	return p.refundErr
+}
+
+type bookingFixture struct {
+	svc      *Service
+	inv      *memInventory
+	bookings *memBookings
+	pay      *fakePayments
+	checkIn  time.Time
+}
+
+// newBookingFixture stocks 2 rooms of types 1 and 2 for the first three nights from checkIn.
+func newBookingFixture() *bookingFixture {
+	f := &bookingFixture{
+		checkIn:  time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC),
+		bookings: &memBookings{byID: map[string]*entity.Booking{}},
+		pay:      &fakePayments{},
+	}
+	f.inv = newMemInventory([]int{1, 2}, f.checkIn, 3, 2)
+	f.svc = NewService(f.inv, f.bookings, f.pay, nil)
+	return f
+}
+
+func (f *bookingFixture) input(nights int, items ...entity.CreateBookingItem) entity.CreateBookingInput {
+	return entity.CreateBookingInput{
+		UserID:   "user-1",
+		Email:    "guest@example.com",
+		CheckIn:  f.checkIn,
+		CheckOut: f.checkIn.AddDate(0, 0, nights),
+		Guests:   2,
+		Items:    items,
+	}
+}
+
+// left returns the stock of a room type for each stocked night.
+func (f *bookingFixture) left(roomTypeID int) [3]int {
+	var out [3]int
+	for d := range out {
+		out[d] = f.inv.stock[roomTypeID][night(f.checkIn, d)]
+	}
+	return out
+}
+
+// book creates a booking of one room of type 1 for two nights and moves it to status.
+func (f *bookingFixture) book(t *testing.T, status entity.Status) *entity.Booking {
+	t.Helper()
+	b, err := f.svc.Create(context.Background(), f.input(2, entity.CreateBookingItem{RoomTypeID: 1, Quantity: 1}))
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	f.bookings.byID[b.ID].Status = status
+	return f.bookings.byID[b.ID]
+}
+
+func TestCreateHoldsStock(t *testing.T) {
+	f := newBookingFixture()
+
+	b, err := f.svc.Create(context.Background(), f.input(2, entity.CreateBookingItem{RoomTypeID: 1, Quantity: 2}, entity.CreateBookingItem{RoomTypeID: 2, Quantity: 1}))
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if b.Status != entity.StatusUnpaid || b.Total != 3*2*nightPrice {
+		t.Errorf("booking status = %s, total = %d, want %s and %d", b.Status, b.Total, entity.StatusUnpaid, 3*2*nightPrice)
+	}
+	if _, ok := f.bookings.byID[b.ID]; !ok {
+		t.Error("booking was not saved")
+	}
+	if got, want := f.left(1), [3]int{0, 0, 2}; got != want {
+		t.Errorf("room type 1 stock = %v, want %v", got, want)
+	}
+	if got, want := f.left(2), [3]int{1, 1, 2}; got != want {
+		t.Errorf("room type 2 stock = %v, want %v", got, want)
+	}
+	if len(f.pay.requested) != 1 || f.pay.requested[0] != b.ID {
+		t.Errorf("payment requests = %v, want one for %s", f.pay.requested, b.ID)
+	}
+}
+
+func TestCreateOutOfStockOnOneNight(t *testing.T) {
+	f := newBookingFixture()
+	f.inv.stock[2][night(f.checkIn, 1)] = 0
+
+	_, err := f.svc.Create(context.Background(), f.input(3, entity.CreateBookingItem{RoomTypeID: 1, Quantity: 1}, entity.CreateBookingItem{RoomTypeID: 2, Quantity: 1}))
+	if !errors.Is(err, entity.ErrNoAvailability) {
+		t.Fatalf("Create() error = %v, want %v", err, entity.ErrNoAvailability)
+	}
+	if got, want := f.left(1), [3]int{2, 2, 2}; got != want {
+		t.Errorf("room type 1 stock = %v, want the earlier hold released to %v", got, want)
+	}
+	if got, want := f.left(2), [3]int{2, 0, 2}; got != want {
+		t.Errorf("room type 2 stock = %v, want untouched %v", got, want)
+	}
+	if len(f.bookings.byID) != 0 || len(f.pay.requested) != 0 {
+		t.Errorf("bookings = %d, payment requests = %d, want none", len(f.bookings.byID), len(f.pay.requested))
+	}
+}
+
+func TestCreateReleasesHoldsWhenSaveFails(t *testing.T) {
+	f := newBookingFixture()
+	f.bookings.createErr = errors.New("connection reset")
+
+	_, err := f.svc.Create(context.Background(), f.input(2, entity.CreateBookingItem{RoomTypeID: 1, Quantity: 1}, entity.CreateBookingItem{RoomTypeID: 2, Quantity: 2}))
+	if !errors.Is(err, f.bookings.createErr) {
+		t.Fatalf("Create() error = %v, want %v", err, f.bookings.createErr)
+	}
+	if got, want := f.left(1), [3]int{2, 2, 2}; got != want {
+		t.Errorf("room type 1 stock = %v, want %v", got, want)
+	}
+	if got, want := f.left(2), [3]int{2, 2, 2}; got != want {
+		t.Errorf("room type 2 stock = %v, want %v", got, want)
+	}
+	if len(f.pay.requested) != 0 {
+		t.Errorf("payment requests = %v, want none", f.pay.requested)
+	}
+}
+
+func TestRepoUpdateStatusTransitions(t *testing.T) {
+	tests := []struct {
+		from    entity.Status
+		to      entity.Status
+		allowed bool
+	}{
+		{entity.StatusUnpaid, entity.StatusPaid, true},
+		{entity.StatusCancelled, entity.StatusPaid, false},
+		{entity.StatusPaid, entity.StatusPaid, false},
+		{entity.StatusRefundPending, entity.StatusPaid, false},
+		{entity.StatusCheckedIn, entity.StatusPaid, false},
+		{entity.StatusPaid, entity.StatusRefunded, true},
+		{entity.StatusRefundPending, entity.StatusRefunded, true},
+		{entity.StatusUnpaid, entity.StatusRefunded, false},
+		{entity.StatusCancelled, entity.StatusRefunded, false},
+		{entity.StatusCheckedIn, entity.StatusRefunded, false},
+		{entity.StatusCheckedOut, entity.StatusRefunded, false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"->"+string(tt.to), func(t *testing.T) {
+			f := newBookingFixture()
+			b := f.book(t, tt.from)
+			held := f.left(1)
+
+			err := f.svc.RepoUpdateStatus(context.Background(), b.ID, tt.to)
+			if tt.allowed && err != nil {
+				t.Fatalf("RepoUpdateStatus() error = %v", err)
+			}
+			if !tt.allowed && !errors.Is(err, ErrBookingAlreadyHandled) {
+				t.Fatalf("RepoUpdateStatus() error = %v, want %v", err, ErrBookingAlreadyHandled)
+			}
+
+			want := tt.from
+			if tt.allowed {
+				want = tt.to
+			}
+			if b.Status != want {
+				t.Errorf("status = %s, want %s", b.Status, want)
+			}
+			// stock goes back exactly when the booking stops holding it
+			if tt.allowed && !holdsInventory(tt.to) {
+				held = [3]int{2, 2, 2}
+			}
+			if got := f.left(1); got != held {
+				t.Errorf("stock = %v, want %v", got, held)
+			}
+		})
+	}
+}
+
+func TestRefundRetryFromRefundPending(t *testing.T) {
+	f := newBookingFixture()
+	b := f.book(t, entity.StatusPaid)
+	f.pay.refundErr = errors.New("payment service unavailable")
+
+	if _, err := f.svc.Refund(context.Background(), b.ID, ""); !errors.Is(err, ErrRefundFailed) {
+		t.Fatalf("first Refund() error = %v, want %v", err, ErrRefundFailed)
+	}
+	if b.Status != entity.StatusRefundPending {
+		t.Fatalf("status after failed refund = %s, want %s", b.Status, entity.StatusRefundPending)
+	}
+	if got, want := f.left(1), [3]int{1, 1, 2}; got != want {
+		t.Errorf("stock after failed refund = %v, want still held %v", got, want)
+	}
+
+	f.pay.refundErr = nil
+	got, err := f.svc.Refund(context.Background(), b.ID, "")
+	if err != nil {
+		t.Fatalf("retried Refund() error = %v", err)
+	}
+	if got.Status != entity.StatusCancelled || b.Status != entity.StatusCancelled {
+		t.Errorf("status after retry = %s (stored %s), want %s", got.Status, b.Status, entity.StatusCancelled)
+	}
+	want := "cancel:" + b.ID
+	if len(f.pay.refunds) != 2 || f.pay.refunds[0] != want || f.pay.refunds[1] != want {
+		t.Errorf("refund references = %v, want %q twice", f.pay.refunds, want)
+	}
+	if got, want := f.left(1), [3]int{2, 2, 2}; got != want {
+		t.Errorf("stock after retry = %v, want released %v", got, want)
+	}
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"booking/internal/entity"
+	"context"
+	"log"
+	"time"
+)
+
+// DefaultPaymentTTL is how long an UNPAID booking holds inventory when not configured.
+const DefaultPaymentTTL = 30 * time.Minute
+
+const expiryBatchSize = 100
+
+// ExpireOverdue cancels UNPAID bookings past their payment deadline, releases their
+// inventory and asks the payment service to expire the pending payment.
+// It returns the number of bookings cancelled.
+func (s *Service) ExpireOverdue(ctx context.Context) (int, error) {
+	list, err := s.repo.ListOverdueUnpaid(ctx, s.clock().UTC(), expiryBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for i := range list {
+		b := &list[i]
+		// Guard against a payment settling between the listing and the update.
+		changed, err := s.repo.UpdateStatusFrom(ctx, b.ID, entity.StatusUnpaid, entity.StatusCancelled)
+		if err != nil {
+			return expired, err
+		}
+		if !changed {
+			continue
+		}
+		b.Status = entity.StatusCancelled
+		s.releaseBooking(b)
+		if s.notify != nil {
+			if err := s.notify.ExpirePayment(ctx, b.ID); err != nil {
+				log.Printf("expire payment booking_id=%s: %v", b.ID, err)
+			}
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+// RunExpirySweeper calls ExpireOverdue every interval until ctx is cancelled.
+func (s *Service) RunExpirySweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := s.ExpireOverdue(ctx)
+			if err != nil {
+				log.Printf("expiry sweeper: %v", err)
+				continue
+			}
+			if n > 0 {
+				log.Printf("expiry sweeper: cancelled %d overdue bookings", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"booking/internal/entity"
+)
+
+// fakeNotifier records the payments the sweeper asked to expire.
+type fakeNotifier struct {
+	expired []string
+}
+
+func (n *fakeNotifier) ExpirePayment(_ context.Context, bookingID string) error {
+	n.expired = append(n.expired, bookingID)
+	return nil
+}
+
+func TestExpireOverdueCancelsUnpaidBookings(t *testing.T) {
+	f := newBookingFixture()
+	notify := &fakeNotifier{}
+	f.svc.notify = notify
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	f.svc.clock = func() time.Time { return now }
+
+	overdue := f.book(t, entity.StatusUnpaid)
+	overdue.PaymentDueAt = ptr(now.Add(-time.Minute))
+	current := f.book(t, entity.StatusUnpaid)
+	current.PaymentDueAt = ptr(now.Add(time.Minute))
+
+	n, err := f.svc.ExpireOverdue(context.Background())
+	if err != nil {
+		t.Fatalf("ExpireOverdue() error = %v", err)
+	}
+	if n != 1 {
+		t.Errorf("expired = %d, want 1", n)
+	}
+	if overdue.Status != entity.StatusCancelled || current.Status != entity.StatusUnpaid {
+		t.Errorf("statuses = %s and %s, want %s and %s", overdue.Status, current.Status, entity.StatusCancelled, entity.StatusUnpaid)
+	}
+	// only the booking still waiting for payment keeps its room
+	if got, want := f.left(1), [3]int{1, 1, 2}; got != want {
+		t.Errorf("stock = %v, want %v", got, want)
+	}
+	if len(notify.expired) != 1 || notify.expired[0] != overdue.ID {
+		t.Errorf("expired payments = %v, want [%s]", notify.expired, overdue.ID)
+	}
+}
+
+func TestExpireOverdueSkipsBookingPaidMeanwhile(t *testing.T) {
+	f := newBookingFixture()
+	notify := &fakeNotifier{}
+	f.svc.notify = notify
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	f.svc.clock = func() time.Time { return now }
+
+	b := f.book(t, entity.StatusUnpaid)
+	b.PaymentDueAt = ptr(now.Add(-time.Minute))
+	f.bookings.afterList = func() {
+		if err := f.svc.RepoUpdateStatus(context.Background(), b.ID, entity.StatusPaid); err != nil {
+			t.Fatalf("settle payment: %v", err)
+		}
+	}
+
+	n, err := f.svc.ExpireOverdue(context.Background())
+	if err != nil {
+		t.Fatalf("ExpireOverdue() error = %v", err)
+	}
+	if n != 0 || b.Status != entity.StatusPaid {
+		t.Errorf("expired = %d, status = %s, want 0 and %s", n, b.Status, entity.StatusPaid)
+	}
+	if got, want := f.left(1), [3]int{1, 1, 2}; got != want {
+		t.Errorf("stock = %v, want the paid booking to keep %v", got, want)
+	}
+	if len(notify.expired) != 0 {
+		t.Errorf("expired payments = %v, want none", notify.expired)
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
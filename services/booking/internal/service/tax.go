@@ -0,0 +1,71 @@
+package service
+
+import (
+	"booking/internal/entity"
+	"math"
+)
+
+// computeTaxes applies rules to every room-night of the items and returns one line per
+// matching rule, in rule order, plus the exclusive amount to add to the subtotal.
+func computeTaxes(rules []entity.TaxRule, items []entity.BookingItem) ([]entity.BookingTax, int64) {
+	if len(rules) == 0 {
+		return nil, 0
+	}
+
+	amounts := make([]int64, len(rules))
+	matched := make([]bool, len(rules))
+	for _, it := range items {
+		qty := int64(it.Quantity)
+		for _, night := range it.NightlyRates {
+			base := night.Price * qty
+			exclusive := int64(0)
+			for i, r := range rules {
+				if !r.Applies(it.RoomTypeID, night.Date) {
+					continue
+				}
+				matched[i] = true
+
+				var tax int64
+				switch {
+				case r.Type == entity.TaxFixed:
+					tax = r.Amount * qty
+				case r.Inclusive:
+					// portion of the price that is tax: base - base/(1+p)
+					tax = base - int64(math.Round(float64(base)/(1+r.Percent/100)))
+				case r.Compound:
+					tax = int64(math.Round(float64(base+exclusive) * r.Percent / 100))
+				default:
+					tax = int64(math.Round(float64(base) * r.Percent / 100))
+				}
+
+				amounts[i] += tax
+				if !r.Inclusive {
+					exclusive += tax
+				}
+			}
+		}
+	}
+
+	var lines []entity.BookingTax
+	var total int64
+	for i, r := range rules {
+		if !matched[i] {
+			continue
+		}
+		line := entity.BookingTax{
+			Code:      r.Code,
+			Name:      r.Name,
+			Type:      r.Type,
+			Inclusive: r.Inclusive,
+			Amount:    amounts[i],
+		}
+		if r.Type == entity.TaxPercent {
+			line.Percent = r.Percent
+		}
+		lines = append(lines, line)
+		if !r.Inclusive {
+			total += amounts[i]
+		}
+	}
+	return lines, total
+}
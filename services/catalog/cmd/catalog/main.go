@@ -9,7 +9,9 @@ import (
 	"net/http"
 	"os"
 
+	"pkg/authx"
 	"pkg/dbx"
+	"pkg/hmacx"
 
 	"github.com/gin-gonic/gin"
 )
@@ -25,6 +27,11 @@ func main() {
 		log.Fatalf("auto migrate catalog schema: %v", err)
 	}
 
+	internalSecret := os.Getenv("INTERNAL_API_SECRET")
+	if internalSecret == "" {
+		log.Fatal("INTERNAL_API_SECRET env is required")
+	}
+
 	rtRepo := repo.NewRoomTypeRepository(db)
 	invRepo := repo.NewInventoryRepository(db)
 	svc := service.NewCatalogService(rtRepo, invRepo)
@@ -34,8 +41,14 @@ func main() {
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "ok"})
 	})
-	r.POST("/internal/seed", h.Seed)
 	r.GET("/catalog/availability", h.Availability)
+	r.GET("/catalog/quote", h.Quote)
+
+	internal := r.Group("/internal")
+	internal.Use(authx.Internal(hmacx.NewVerifier(internalSecret)))
+	internal.POST("/seed", h.Seed)
+	internal.POST("/inventory/hold", h.Hold)
+	internal.POST("/inventory/release", h.Release)
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -1,7 +1,10 @@
 package handler
 
 import (
+	"catalog/internal/repo"
 	"catalog/internal/service"
+	"context"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
@@ -55,3 +58,77 @@ func (h *CatalogHandler) Availability(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"data": items})
 }
+
+// Quote returns the per-night price breakdown of a room type for the requested range.
+func (h *CatalogHandler) Quote(c *gin.Context) {
+	from, errIn := time.Parse("2006-01-02", c.Query("check_in"))
+	to, errOut := time.Parse("2006-01-02", c.Query("check_out"))
+	if errIn != nil || errOut != nil || !to.After(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date range"})
+		return
+	}
+
+	roomTypeID, err := strconv.ParseUint(c.Query("room_type_id"), 10, 64)
+	if err != nil || roomTypeID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid room_type_id"})
+		return
+	}
+
+	quote, err := h.svc.Quote(c.Request.Context(), uint(roomTypeID), from, to)
+	if err != nil {
+		if errors.Is(err, service.ErrRoomTypeNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": quote})
+}
+
+type holdRequest struct {
+	RoomTypeID uint   `json:"room_type_id" binding:"required"`
+	CheckIn    string `json:"check_in" binding:"required"`
+	CheckOut   string `json:"check_out" binding:"required"`
+	Quantity   int    `json:"quantity" binding:"required,min=1"`
+}
+
+// Hold decrements availability for every night of the requested stay.
+func (h *CatalogHandler) Hold(c *gin.Context) {
+	h.handleHold(c, h.svc.Hold)
+}
+
+// Release restores availability for every night of the requested stay.
+func (h *CatalogHandler) Release(c *gin.Context) {
+	h.handleHold(c, h.svc.Release)
+}
+
+func (h *CatalogHandler) handleHold(c *gin.Context, fn func(ctx context.Context, roomTypeID uint, from, to time.Time, qty int) error) {
+	var req holdRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	from, errIn := time.Parse("2006-01-02", req.CheckIn)
+	to, errOut := time.Parse("2006-01-02", req.CheckOut)
+	if errIn != nil || errOut != nil || !to.After(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date range"})
+		return
+	}
+
+	if err := fn(c.Request.Context(), req.RoomTypeID, from, to, req.Quantity); err != nil {
+		switch {
+		case errors.Is(err, repo.ErrInsufficientInventory):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrInvalidHold):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
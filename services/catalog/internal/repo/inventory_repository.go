@@ -3,6 +3,7 @@ package repo
 import (
 	"catalog/internal/entity"
 	"context"
+	"errors"
 	"time"
 
 	"gorm.io/gorm"
@@ -13,10 +14,16 @@ import (
 type InventoryRepository interface {
 	Upsert(ctx context.Context, inv *entity.RoomInventory) error
 	MinAvailable(ctx context.Context, roomTypeID uint, from, to time.Time) (int, error)
-	Prices(ctx context.Context, roomTypeID uint, from, to time.Time) ([]int64, error)
+	// Prices returns the price overrides in [from, to) keyed by night (YYYY-MM-DD).
+	Prices(ctx context.Context, roomTypeID uint, from, to time.Time) (map[string]int64, error)
+	Hold(ctx context.Context, roomTypeID uint, from, to time.Time, qty int) error
+	Release(ctx context.Context, roomTypeID uint, from, to time.Time, qty int) error
 	DeleteAll(ctx context.Context) error
 }
 
+// ErrInsufficientInventory is returned when at least one night in the range cannot satisfy a hold.
+var ErrInsufficientInventory = errors.New("insufficient inventory for requested range")
+
 type inventoryRepository struct {
 	db *gorm.DB
 }
@@ -50,25 +57,61 @@ func (r *inventoryRepository) MinAvailable(ctx context.Context, roomTypeID uint,
 	return *minAvail, nil
 }
 
-func (r *inventoryRepository) Prices(ctx context.Context, roomTypeID uint, from, to time.Time) ([]int64, error) {
+func (r *inventoryRepository) Prices(ctx context.Context, roomTypeID uint, from, to time.Time) (map[string]int64, error) {
 	var rows []entity.RoomInventory
 	if err := r.db.WithContext(ctx).
-		Where("room_type_id = ? AND inv_date >= ? AND inv_date < ?", roomTypeID, from, to).
-		Order("inv_date ASC").
+		Where("room_type_id = ? AND inv_date >= ? AND inv_date < ? AND price_override IS NOT NULL", roomTypeID, from, to).
 		Find(&rows).Error; err != nil {
 		return nil, err
 	}
-	prices := make([]int64, len(rows))
-	for i, row := range rows {
-		if row.PriceOverride != nil {
-			prices[i] = *row.PriceOverride
-			continue
-		}
-		prices[i] = -1
+	prices := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		prices[row.InvDate.Format("2006-01-02")] = *row.PriceOverride
 	}
 	return prices, nil
 }
 
+// Hold decrements available_rooms for every night in [from, to). The whole
+// range is rejected when any night is missing or lacks enough stock.
+func (r *inventoryRepository) Hold(ctx context.Context, roomTypeID uint, from, to time.Time, qty int) error {
+	nights := daysBetween(from, to)
+	if nights <= 0 || qty <= 0 {
+		return ErrInsufficientInventory
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&entity.RoomInventory{}).
+			Where("room_type_id = ? AND inv_date >= ? AND inv_date < ? AND available_rooms >= ?", roomTypeID, from, to, qty).
+			Update("available_rooms", gorm.Expr("available_rooms - ?", qty))
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected != int64(nights) {
+			// rollback partial decrement
+			return ErrInsufficientInventory
+		}
+		return nil
+	})
+}
+
+// Release increments available_rooms for every night in [from, to), capped at total_rooms.
+func (r *inventoryRepository) Release(ctx context.Context, roomTypeID uint, from, to time.Time, qty int) error {
+	if qty <= 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).
+		Model(&entity.RoomInventory{}).
+		Where("room_type_id = ? AND inv_date >= ? AND inv_date < ?", roomTypeID, from, to).
+		Update("available_rooms", gorm.Expr("LEAST(available_rooms + ?, total_rooms)", qty)).Error
+}
+
+func daysBetween(from, to time.Time) int {
+	n := int(to.Sub(from).Hours() / 24)
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
 func (r *inventoryRepository) DeleteAll(ctx context.Context) error {
 	return r.db.WithContext(ctx).Where("1 = 1").Delete(&entity.RoomInventory{}).Error
 }
@@ -4,9 +4,13 @@ import (
 	"catalog/internal/entity"
 	"catalog/internal/repo"
 	"context"
+	"errors"
 	"time"
 )
 
+// ErrInvalidHold is returned when a hold/release request has a bad range or quantity.
+var ErrInvalidHold = errors.New("invalid hold request")
+
 // AvailabilityItem represents the availability response for a room type.
 type AvailabilityItem struct {
 	RoomTypeID    int    `json:"room_type_id"`
@@ -17,6 +21,22 @@ type AvailabilityItem struct {
 	TotalPrice    int64  `json:"total_price"`
 }
 
+// NightlyRate is the price of a single night in a stay.
+type NightlyRate struct {
+	Date  string `json:"date"`
+	Price int64  `json:"price"`
+}
+
+// Quote is the per-night price breakdown of a room type for a stay.
+type Quote struct {
+	RoomTypeID int           `json:"room_type_id"`
+	Nights     []NightlyRate `json:"nights"`
+	Total      int64         `json:"total"`
+}
+
+// ErrRoomTypeNotFound is returned when a quote targets an unknown room type.
+var ErrRoomTypeNotFound = errors.New("room type not found")
+
 // CatalogService orchestrates catalog business use-cases.
 type CatalogService struct {
 	roomTypes repo.RoomTypeRepository
@@ -125,25 +145,14 @@ func (s *CatalogService) Availability(ctx context.Context, from, to time.Time, g
 			continue
 		}
 
-		overrides, err := s.inventory.Prices(ctx, rt.ID, from, to)
+		rates, err := s.nightlyRates(ctx, rt, from, to)
 		if err != nil {
 			return nil, err
 		}
 
-		var (
-			pricePerNight = rt.BasePrice
-			total         int64
-		)
-
-		for i := 0; i < nights; i++ {
-			price := rt.BasePrice
-			if i < len(overrides) && overrides[i] >= 0 {
-				price = overrides[i]
-			}
-			if i == 0 {
-				pricePerNight = price
-			}
-			total += price
+		var total int64
+		for _, r := range rates {
+			total += r.Price
 		}
 
 		items = append(items, AvailabilityItem{
@@ -151,10 +160,71 @@ func (s *CatalogService) Availability(ctx context.Context, from, to time.Time, g
 			Name:          rt.Name,
 			Capacity:      rt.Capacity,
 			Available:     minAvail,
-			PricePerNight: pricePerNight,
+			PricePerNight: rates[0].Price,
 			TotalPrice:    total,
 		})
 	}
 
 	return items, nil
 }
+
+// Quote returns the nightly rates of a room type for [from, to), applying price overrides.
+func (s *CatalogService) Quote(ctx context.Context, roomTypeID uint, from, to time.Time) (*Quote, error) {
+	if daysBetween(from, to) <= 0 {
+		return nil, ErrInvalidHold
+	}
+	types, err := s.roomTypes.GetByIDs(ctx, []uint{roomTypeID})
+	if err != nil {
+		return nil, err
+	}
+	if len(types) == 0 {
+		return nil, ErrRoomTypeNotFound
+	}
+
+	rates, err := s.nightlyRates(ctx, types[0], from, to)
+	if err != nil {
+		return nil, err
+	}
+	q := &Quote{RoomTypeID: int(roomTypeID), Nights: rates}
+	for _, r := range rates {
+		q.Total += r.Price
+	}
+	return q, nil
+}
+
+// nightlyRates resolves the price of each night, falling back to the base price without an override.
+func (s *CatalogService) nightlyRates(ctx context.Context, rt entity.RoomType, from, to time.Time) ([]NightlyRate, error) {
+	nights := daysBetween(from, to)
+	overrides, err := s.inventory.Prices(ctx, rt.ID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	// overrides are keyed by date since nights without an inventory row have none
+	rates := make([]NightlyRate, nights)
+	for i := 0; i < nights; i++ {
+		date := from.AddDate(0, 0, i).Format("2006-01-02")
+		price := rt.BasePrice
+		if override, ok := overrides[date]; ok {
+			price = override
+		}
+		rates[i] = NightlyRate{Date: date, Price: price}
+	}
+	return rates, nil
+}
+
+// Hold reserves qty rooms of the given type for each night in [from, to).
+func (s *CatalogService) Hold(ctx context.Context, roomTypeID uint, from, to time.Time, qty int) error {
+	if roomTypeID == 0 || qty <= 0 || daysBetween(from, to) <= 0 {
+		return ErrInvalidHold
+	}
+	return s.inventory.Hold(ctx, roomTypeID, from, to, qty)
+}
+
+// Release returns qty rooms of the given type for each night in [from, to).
+func (s *CatalogService) Release(ctx context.Context, roomTypeID uint, from, to time.Time, qty int) error {
+	if roomTypeID == 0 || qty <= 0 || daysBetween(from, to) <= 0 {
+		return ErrInvalidHold
+	}
+	return s.inventory.Release(ctx, roomTypeID, from, to, qty)
+}
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"payment/internal/entity"
 	"payment/internal/handler"
@@ -11,6 +13,7 @@ import (
 	"payment/internal/service"
 
 	"pkg/dbx"
+	"pkg/hmacx"
 	"pkg/jwtx"
 
 	"github.com/gin-gonic/gin"
@@ -28,16 +31,42 @@ func main() {
 	pRepo := repo.NewPaymentRepository(db)
 	rRepo := repo.NewRefundRepository(db)
 	// Booking client base URL from env (defaults inside ctor if empty)
-	bClient := repo.NewBookingHTTPClient(os.Getenv("BOOKING_BASE_URL"))
-	svc := service.NewPaymentService(pRepo, rRepo, bClient)
+	internalSecret := os.Getenv("INTERNAL_API_SECRET")
+	if internalSecret == "" {
+		log.Fatal("INTERNAL_API_SECRET env is required")
+	}
+	signer := hmacx.NewSigner("payment", internalSecret)
+	bClient := repo.NewBookingHTTPClient(os.Getenv("BOOKING_BASE_URL"), signer)
+	serverKey := os.Getenv("MIDTRANS_SERVER_KEY")
+	if serverKey == "" {
+		log.Fatal("MIDTRANS_SERVER_KEY env is required")
+	}
+	svc := service.NewPaymentService(pRepo, rRepo, bClient, serverKey)
 	// JWT
 	jwtSecret := os.Getenv("JWT_SECRET")
 	if jwtSecret == "" {
 		jwtSecret = "dev-secret"
 	}
-	tm := jwtx.New(jwtSecret, "go-hotel-book")
+	authBase := os.Getenv("AUTH_BASE_URL")
+	if authBase == "" {
+		authBase = "http://auth:8001"
+	}
+	revocations := jwtx.NewRevocationCache(authBase, signer)
+	go revocations.Run(context.Background(), 30*time.Second)
+	tokenOpts := []jwtx.Option{jwtx.WithRevocationChecker(revocations)}
+	// With a JWKS URL only auth-signed asymmetric tokens are accepted.
+	if jwksURL := os.Getenv("JWT_JWKS_URL"); jwksURL != "" {
+		tokenOpts = append(tokenOpts, jwtx.WithKeySource(jwtx.NewJWKSCache(jwksURL)))
+	}
+	tm := jwtx.New(jwtSecret, "go-hotel-book", tokenOpts...)
 
-	h := handler.NewHandler(svc, tm)
+	h := handler.NewHandler(svc, tm, hmacx.NewVerifier(internalSecret))
+	// Must match the booking service's guest token secret.
+	guestSecret := os.Getenv("GUEST_TOKEN_SECRET")
+	if guestSecret == "" {
+		log.Fatal("GUEST_TOKEN_SECRET env is required")
+	}
+	h.SetGuestTokens(jwtx.New(guestSecret, "booking-guest"))
 
 	r := gin.Default()
 	r.GET("/health", func(c *gin.Context) {
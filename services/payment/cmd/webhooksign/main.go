@@ -0,0 +1,44 @@
+// This is a helper for simulating Midtrans notifications locally.
+// It prints a webhook body signed with MIDTRANS_SERVER_KEY.
+// CLI: MIDTRANS_SERVER_KEY=... go run ./cmd/webhooksign BO-<booking_id> settlement 150000.00
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"payment/internal/service"
+)
+
+func main() {
+	if len(os.Args) < 4 {
+		fmt.Println("usage: go run ./cmd/webhooksign <order_id> <transaction_status> <gross_amount> [status_code]")
+		return
+	}
+	serverKey := os.Getenv("MIDTRANS_SERVER_KEY")
+	if serverKey == "" {
+		fmt.Println("MIDTRANS_SERVER_KEY env is required")
+		os.Exit(1)
+	}
+
+	statusCode := "200"
+	if len(os.Args) > 4 {
+		statusCode = os.Args[4]
+	}
+	p := service.MidtransWebhookPayload{
+		OrderID:           os.Args[1],
+		TransactionStatus: os.Args[2],
+		StatusCode:        statusCode,
+		GrossAmount:       os.Args[3],
+		TransactionID:     "trx-local",
+	}
+	service.SignMidtransPayload(&p, serverKey)
+
+	out, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(out))
+}
@@ -1,24 +1,62 @@
 package entity
 
-import "context"
+import (
+	"context"
+	"errors"
+)
+
+var (
+	// ErrBookingNotFound is returned by BookingClient for unknown bookings.
+	ErrBookingNotFound = errors.New("booking not found")
+	// ErrBookingStatusConflict is returned by BookingClient when the booking's current
+	// status does not allow the requested change.
+	ErrBookingStatusConflict = errors.New("booking status does not allow the change")
+)
 
 // PaymentRepo defines storage operations for Payment entities.
 type PaymentRepo interface {
 	Create(ctx context.Context, p *Payment) error
+	FindByID(ctx context.Context, id string) (*Payment, error)
 	FindByOrderID(ctx context.Context, orderID string) (*Payment, error)
-	UpdateStatus(ctx context.Context, id string, status PaymentStatus, raw string, providerRef string) error
-	ListByUserID(ctx context.Context, userID string) ([]Payment, error)
-	// GetBookingTotal returns the total price of a booking from booking schema
-	GetBookingTotal(ctx context.Context, bookingID string) (int64, error)
+	FindByIdempotencyKey(ctx context.Context, bookingID, key string) (*Payment, error)
+	// UpdateStatusFrom moves a payment to status only while it is in one of from, and
+	// reports whether it did, so stale or replayed notifications cannot rewind it.
+	UpdateStatusFrom(ctx context.Context, id string, from []PaymentStatus, status PaymentStatus, raw string, providerRef string) (bool, error)
+	ListByBookingID(ctx context.Context, bookingID string) ([]Payment, error)
+	ListByBookingIDs(ctx context.Context, bookingIDs []string) ([]Payment, error)
 }
 
 // RefundRepo defines storage operations for Refund entities.
 type RefundRepo interface {
 	Create(ctx context.Context, r *Refund) error
+	// Record locks the payment, applies the refund to its balance and stores both atomically.
+	Record(ctx context.Context, r *Refund) (*Payment, error)
+	ListByPaymentID(ctx context.Context, paymentID string) ([]Refund, error)
+}
+
+// BookingInfo is the part of a booking the payment service relies on. UserID is empty
+// for guest bookings.
+type BookingInfo struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+	Status string `json:"status"`
+	Total  int64  `json:"total"`
 }
 
-// BookingClient abstracts calls to the Booking service for status updates.
+const (
+	// BookingUnpaid is the booking status in which a payment may be opened.
+	BookingUnpaid = "UNPAID"
+	// BookingPaid is the booking status in which an owner may refund a payment.
+	BookingPaid = "PAID"
+	// BookingCancelled is the status of a booking that can no longer be paid.
+	BookingCancelled = "CANCELLED"
+)
+
+// BookingClient abstracts calls to the Booking service.
 type BookingClient interface {
+	GetBooking(ctx context.Context, bookingID string) (*BookingInfo, error)
+	// ListUserBookingIDs returns the IDs of the bookings owned by a user.
+	ListUserBookingIDs(ctx context.Context, userID string) ([]string, error)
 	UpdateStatusPaid(ctx context.Context, bookingID string) error
 	UpdateStatusExpired(ctx context.Context, bookingID string) error
 	UpdateStatusRefunded(ctx context.Context, bookingID string) error
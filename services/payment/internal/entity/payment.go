@@ -1,6 +1,7 @@
 package entity
 
 import (
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,25 +16,72 @@ const (
 	PayExpire     PaymentStatus = "EXPIRE"
 	PayDeny       PaymentStatus = "DENY"
 	PayRefunded   PaymentStatus = "REFUNDED"
+	// PayPartiallyRefunded is a settled payment with part of its amount refunded.
+	PayPartiallyRefunded PaymentStatus = "PARTIALLY_REFUNDED"
 )
 
+var (
+	// ErrPaymentNotRefundable is returned for payments that were never settled or are fully refunded.
+	ErrPaymentNotRefundable = errors.New("payment is not refundable")
+	// ErrRefundExceedsBalance is returned when a refund is larger than the remaining balance.
+	ErrRefundExceedsBalance = errors.New("refund amount exceeds the refundable balance")
+)
+
+// Payment is one payment attempt for a booking. A booking gets a new attempt, with
+// its own order ID, after the previous one expired or was denied. IdempotencyKey is
+// the client's Idempotency-Key header and is unique per booking when set.
 type Payment struct {
-	ID          string `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
-	BookingID   string `gorm:"index"`
-	OrderID     string `gorm:"uniqueIndex"`
-	Amount      int64
-	Provider    string
-	ProviderRef string
-	Status      PaymentStatus `gorm:"index"`
-	RawPayload  string
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	ID             string `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	BookingID      string `gorm:"index;uniqueIndex:idx_payment_booking_idempotency"`
+	OrderID        string `gorm:"uniqueIndex"`
+	Attempt        int
+	IdempotencyKey *string `gorm:"size:255;uniqueIndex:idx_payment_booking_idempotency"`
+	Amount         int64
+	RefundedAmount int64
+	Provider       string
+	ProviderRef    string
+	Status         PaymentStatus `gorm:"index"`
+	RawPayload     string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// RefundableAmount is what is left to refund of a settled payment.
+func (p *Payment) RefundableAmount() int64 {
+	if p.Status != PaySettlement && p.Status != PayPartiallyRefunded {
+		return 0
+	}
+	return p.Amount - p.RefundedAmount
+}
+
+// ApplyRefund adds a refund of amount to the payment and moves it to
+// PARTIALLY_REFUNDED or, once nothing is left, REFUNDED.
+func (p *Payment) ApplyRefund(amount int64) error {
+	left := p.RefundableAmount()
+	if left <= 0 {
+		return ErrPaymentNotRefundable
+	}
+	if amount <= 0 || amount > left {
+		return ErrRefundExceedsBalance
+	}
+	p.RefundedAmount += amount
+	if p.RefundedAmount == p.Amount {
+		p.Status = PayRefunded
+	} else {
+		p.Status = PayPartiallyRefunded
+	}
+	return nil
 }
 
+// Refund is one refund of a payment; a payment may be refunded in several parts.
+// Reference identifies the request that caused it, e.g. a booking cancellation, and
+// is unique per payment when set so a retried request does not refund twice.
 type Refund struct {
-	ID        string `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
-	PaymentID string `gorm:"index"`
+	ID        string  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	PaymentID string  `gorm:"index;uniqueIndex:idx_refund_payment_reference"`
+	Reference *string `gorm:"size:255;uniqueIndex:idx_refund_payment_reference"`
 	Amount    int64
+	Reason    string `gorm:"size:255"`
 	Status    string
 	CreatedAt time.Time
 	UpdatedAt time.Time
@@ -2,20 +2,36 @@ package handler
 
 import (
 	"errors"
+	"io"
 	"net/http"
+	"payment/internal/entity"
 	"payment/internal/service"
+	"pkg/authx"
+	"pkg/hmacx"
 	"pkg/httpx"
 	"pkg/jwtx"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type Handler struct {
-	svc *service.Service
-	tm  *jwtx.TokenManager
+	svc      *service.Service
+	tm       *jwtx.TokenManager
+	internal *hmacx.Verifier
+
+	guestTokens *jwtx.TokenManager
+}
+
+func NewHandler(s *service.Service, tm *jwtx.TokenManager, internal *hmacx.Verifier) *Handler {
+	return &Handler{svc: s, tm: tm, internal: internal}
 }
 
-func NewHandler(s *service.Service, tm *jwtx.TokenManager) *Handler { return &Handler{svc: s, tm: tm} }
+// SetGuestTokens lets holders of a booking-scoped guest token from the booking service
+// pay that booking. It must be called before BindRoutes.
+func (h *Handler) SetGuestTokens(tm *jwtx.TokenManager) {
+	h.guestTokens = tm
+}
 
 type payRequest struct {
 	Amount int64 `json:"amount" binding:"required,gt=0"`
@@ -28,18 +44,38 @@ func (h *Handler) CreatePayment(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
 		return
 	}
-	_, resp, err := h.svc.CreatePayment(c.Request.Context(), bookingID, req.Amount)
+	_, resp, err := h.svc.CreatePayment(c.Request.Context(), bookingID, req.Amount, h.payer(c), c.GetHeader("Idempotency-Key"))
 	if err != nil {
-		if errors.Is(err, service.ErrAmountMismatch) {
-			c.JSON(http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, httpx.ErrorResponse{Error: err.Error()})
+		writeCreatePaymentError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, httpx.OK(resp))
 }
 
+// payer builds the service's view of the caller from an account or guest token.
+func (h *Handler) payer(c *gin.Context) service.Payer {
+	claims := h.getClaims(c)
+	if claims == nil {
+		return service.Payer{}
+	}
+	return service.Payer{UserID: claims.UserID, BookingID: claims.BookingID}
+}
+
+func writeCreatePaymentError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrAmountMismatch), errors.Is(err, service.ErrInvalidIdempotencyKey):
+		c.JSON(http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, service.ErrNotBookingOwner):
+		c.JSON(http.StatusForbidden, httpx.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, entity.ErrBookingNotFound):
+		c.JSON(http.StatusNotFound, httpx.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, service.ErrBookingNotPayable), errors.Is(err, service.ErrIdempotencyKeyReused):
+		c.JSON(http.StatusConflict, httpx.ErrorResponse{Error: err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, httpx.ErrorResponse{Error: err.Error()})
+	}
+}
+
 type createPaymentBody struct {
 	BookingID     string `json:"booking_id" binding:"required"`
 	Amount        int64  `json:"amount" binding:"required,gt=0"`
@@ -54,9 +90,9 @@ func (h *Handler) CreatePaymentBody(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
 		return
 	}
-	_, resp, err := h.svc.CreatePayment(c.Request.Context(), req.BookingID, req.Amount)
+	_, resp, err := h.svc.CreatePayment(c.Request.Context(), req.BookingID, req.Amount, h.payer(c), c.GetHeader("Idempotency-Key"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, httpx.ErrorResponse{Error: err.Error()})
+		writeCreatePaymentError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, httpx.OK(resp))
@@ -65,6 +101,7 @@ func (h *Handler) CreatePaymentBody(c *gin.Context) {
 type webhookPayload struct {
 	OrderID           string `json:"order_id"`
 	TransactionStatus string `json:"transaction_status"`
+	StatusCode        string `json:"status_code"`
 	GrossAmount       string `json:"gross_amount"`
 	TransactionID     string `json:"transaction_id"`
 	SignatureKey      string `json:"signature_key"`
@@ -77,54 +114,146 @@ func (h *Handler) Webhook(c *gin.Context) {
 		return
 	}
 	if err := h.svc.HandleMidtransWebhook(c.Request.Context(), service.MidtransWebhookPayload(payload)); err != nil {
-		c.JSON(http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+		// anything but 200 makes Midtrans redeliver, so only transient failures answer 500
+		switch {
+		case errors.Is(err, service.ErrInvalidSignature):
+			c.JSON(http.StatusForbidden, httpx.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, httpx.ErrorResponse{Error: "payment not found"})
+		case errors.Is(err, service.ErrGrossAmountMismatch), errors.Is(err, service.ErrMissingOrderID):
+			c.JSON(http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, httpx.ErrorResponse{Error: err.Error()})
+		}
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"ok": true})
 }
 
 type refundRequest struct {
-	Amount int64 `json:"amount"`
+	Amount int64  `json:"amount" binding:"gte=0"`
+	Reason string `json:"reason" binding:"max=255"`
 }
 
+// Refund refunds part of a payment, or its whole remaining balance when amount is omitted.
+// Owners may refund their own payments while the booking is PAID; STAFF/ADMIN any payment.
 func (h *Handler) Refund(c *gin.Context) {
 	paymentID := c.Param("id")
 	var req refundRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+		return
+	}
+	claims := h.getClaims(c)
+	if claims == nil {
+		c.JSON(http.StatusUnauthorized, httpx.ErrorResponse{Error: "missing claims"})
+		return
+	}
+	res, err := h.svc.Refund(c.Request.Context(), paymentID, req.Amount, req.Reason, service.Refunder{
+		UserID: claims.UserID,
+		Manage: authx.Can(c, authx.PermPaymentsManage),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, httpx.ErrorResponse{Error: "payment not found"})
+		case errors.Is(err, service.ErrNotBookingOwner):
+			c.JSON(http.StatusForbidden, httpx.ErrorResponse{Error: "forbidden"})
+		case errors.Is(err, entity.ErrRefundExceedsBalance):
+			c.JSON(http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, entity.ErrPaymentNotRefundable), errors.Is(err, service.ErrBookingNotRefundable):
+			c.JSON(http.StatusConflict, httpx.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, httpx.ErrorResponse{Error: err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, httpx.OK(res))
+}
+
+type expireRequest struct {
+	BookingID string `json:"booking_id" binding:"required"`
+}
+
+// InternalExpire expires pending payments for a booking cancelled by the booking service.
+func (h *Handler) InternalExpire(c *gin.Context) {
+	var req expireRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		req.Amount = 0
+		c.JSON(http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+		return
 	}
-	if err := h.svc.Refund(c.Request.Context(), paymentID, req.Amount); err != nil {
+	if err := h.svc.ExpireByBooking(c.Request.Context(), req.BookingID); err != nil {
+		c.JSON(http.StatusInternalServerError, httpx.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+type intentRequest struct {
+	BookingID     string `json:"booking_id" binding:"required"`
+	Amount        int64  `json:"amount" binding:"required,gt=0"`
+	CustomerEmail string `json:"customer_email"`
+}
+
+// InternalCreateIntent opens a payment attempt for a booking the booking service just created.
+func (h *Handler) InternalCreateIntent(c *gin.Context) {
+	var req intentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, httpx.OK(gin.H{"status": "REFUNDED"}))
+	_, resp, err := h.svc.CreateIntent(c.Request.Context(), req.BookingID, req.Amount)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, httpx.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, httpx.OK(resp))
 }
 
-// authMiddleware verifies JWT and injects claims into context
-func (h *Handler) authMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		token, err := jwtx.ExtractToken(c.Request)
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, httpx.ErrorResponse{Error: err.Error()})
-			return
-		}
-		claims, err := h.tm.VerifyToken(token)
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, httpx.ErrorResponse{Error: err.Error()})
+type internalRefundRequest struct {
+	BookingID string `json:"booking_id" binding:"required"`
+	Amount    int64  `json:"amount" binding:"required,gt=0"`
+	Reason    string `json:"reason" binding:"max=255"`
+	Reference string `json:"reference" binding:"required,max=255"`
+}
+
+// InternalRefund refunds a booking's payments when the booking service cancels a paid booking.
+func (h *Handler) InternalRefund(c *gin.Context) {
+	var req internalRefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+		return
+	}
+	refunded, err := h.svc.RefundBooking(c.Request.Context(), req.BookingID, req.Amount, req.Reason, req.Reference)
+	if err != nil {
+		if errors.Is(err, entity.ErrPaymentNotRefundable) {
+			c.JSON(http.StatusConflict, httpx.ErrorResponse{Error: err.Error()})
 			return
 		}
-		c.Set("claims", claims)
-		c.Next()
+		c.JSON(http.StatusInternalServerError, httpx.ErrorResponse{Error: err.Error()})
+		return
 	}
+	c.JSON(http.StatusOK, httpx.OK(gin.H{"booking_id": req.BookingID, "refunded_amount": refunded}))
 }
 
-func (h *Handler) getClaims(c *gin.Context) *jwtx.AccessClaims {
-	v, ok := c.Get("claims")
-	if !ok {
-		return nil
+// InternalListByUser lists a user's payments for other services, e.g. the auth
+// service's personal data export.
+func (h *Handler) InternalListByUser(c *gin.Context) {
+	userID := c.Query("user_id")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, httpx.ErrorResponse{Error: "user_id is required"})
+		return
+	}
+	items, err := h.svc.ListByUserID(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, httpx.ErrorResponse{Error: err.Error()})
+		return
 	}
-	cl, _ := v.(*jwtx.AccessClaims)
-	return cl
+	c.JSON(http.StatusOK, httpx.OK(items))
+}
+
+func (h *Handler) getClaims(c *gin.Context) *jwtx.AccessClaims {
+	return authx.Claims(c)
 }
 
 // GetPayments lists payments for the authenticated user
@@ -146,10 +275,23 @@ func (h *Handler) BindRoutes(r *gin.Engine) {
 	// Public webhook
 	r.POST("/payments/midtrans/webhook", h.Webhook)
 
+	// Internal service-to-service routes
+	internal := r.Group("/internal/payments")
+	internal.Use(authx.Internal(h.internal))
+	internal.GET("", h.InternalListByUser)
+	internal.POST("/expire", h.InternalExpire)
+	internal.POST("/intents", h.InternalCreateIntent)
+	internal.POST("/refunds", h.InternalRefund)
+
 	// Authenticated routes
 	auth := r.Group("")
-	auth.Use(h.authMiddleware())
+	auth.Use(authx.Authenticate(h.tm))
 	auth.POST("/bookings/:id/pay", h.CreatePayment)
 	auth.POST("/payments/:id/refund", h.Refund)
 	auth.GET("/payments", h.GetPayments)
+
+	// Guests without an account pay with the token from the booking service's guest lookup
+	if h.guestTokens != nil {
+		r.POST("/guest/bookings/:id/pay", authx.AuthenticateGuest(h.guestTokens, "id"), h.CreatePayment)
+	}
 }
@@ -6,22 +6,90 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"time"
+
+	"payment/internal/entity"
+
+	"pkg/hmacx"
 )
 
 type bookingHTTP struct {
-	base string
-	cli  *http.Client
+	base   string
+	cli    *http.Client
+	signer *hmacx.Signer
 }
 
-func NewBookingHTTPClient(base string) *bookingHTTP {
+func NewBookingHTTPClient(base string, signer *hmacx.Signer) *bookingHTTP {
 	if base == "" {
 		base = "http://booking:8003"
 	}
 	return &bookingHTTP{
-		base: base,
-		cli:  &http.Client{Timeout: 5 * time.Second},
+		base:   base,
+		cli:    &http.Client{Timeout: 5 * time.Second},
+		signer: signer,
+	}
+}
+
+func (b *bookingHTTP) GetBooking(ctx context.Context, bookingID string) (*entity.BookingInfo, error) {
+	url := fmt.Sprintf("%s/internal/bookings/%s", b.base, bookingID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.signer.Sign(req, nil); err != nil {
+		return nil, err
+	}
+	res, err := b.cli.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, entity.ErrBookingNotFound
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("booking lookup failed: %s", res.Status)
+	}
+	var body struct {
+		Data entity.BookingInfo `json:"data"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, err
 	}
+	return &body.Data, nil
+}
+
+func (b *bookingHTTP) ListUserBookingIDs(ctx context.Context, userID string) ([]string, error) {
+	u := fmt.Sprintf("%s/internal/bookings?user_id=%s", b.base, url.QueryEscape(userID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.signer.Sign(req, nil); err != nil {
+		return nil, err
+	}
+	res, err := b.cli.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("booking list failed: %s", res.Status)
+	}
+	var body struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(body.Data))
+	for _, booking := range body.Data {
+		ids = append(ids, booking.ID)
+	}
+	return ids, nil
 }
 
 func (b *bookingHTTP) postStatus(ctx context.Context, bookingID, status string) error {
@@ -33,12 +101,20 @@ func (b *bookingHTTP) postStatus(ctx context.Context, bookingID, status string)
 		return err
 	}
 	req.Header.Set("Content-Type", "application/json")
+	if err := b.signer.Sign(req, body); err != nil {
+		return err
+	}
 	res, err := b.cli.Do(req)
 	if err != nil {
 		return err
 	}
 	defer res.Body.Close()
-	if res.StatusCode < 200 || res.StatusCode >= 300 {
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return entity.ErrBookingNotFound
+	case res.StatusCode == http.StatusConflict:
+		return entity.ErrBookingStatusConflict
+	case res.StatusCode < 200 || res.StatusCode >= 300:
 		return fmt.Errorf("booking status update failed: %s", res.Status)
 	}
 	return nil
@@ -19,6 +19,14 @@ func (r *paymentRepository) Create(ctx context.Context, p *entity.Payment) error
 	return r.db.WithContext(ctx).Create(p).Error
 }
 
+func (r *paymentRepository) FindByID(ctx context.Context, id string) (*entity.Payment, error) {
+	var p entity.Payment
+	if err := r.db.WithContext(ctx).First(&p, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
 func (r *paymentRepository) FindByOrderID(ctx context.Context, orderID string) (*entity.Payment, error) {
 	var p entity.Payment
 	if err := r.db.WithContext(ctx).First(&p, "order_id = ?", orderID).Error; err != nil {
@@ -27,45 +35,50 @@ func (r *paymentRepository) FindByOrderID(ctx context.Context, orderID string) (
 	return &p, nil
 }
 
-func (r *paymentRepository) UpdateStatus(ctx context.Context, id string, status entity.PaymentStatus, raw string, providerRef string) error {
+func (r *paymentRepository) FindByIdempotencyKey(ctx context.Context, bookingID, key string) (*entity.Payment, error) {
+	var p entity.Payment
+	if err := r.db.WithContext(ctx).First(&p, "booking_id = ? AND idempotency_key = ?", bookingID, key).Error; err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *paymentRepository) UpdateStatusFrom(ctx context.Context, id string, from []entity.PaymentStatus, status entity.PaymentStatus, raw string, providerRef string) (bool, error) {
 	res := r.db.WithContext(ctx).
 		Model(&entity.Payment{}).
-		Where("id = ?", id).
+		Where("id = ? AND status IN ?", id, from).
 		Updates(map[string]any{
 			"status":       status,
 			"raw_payload":  raw,
 			"provider_ref": providerRef,
 		})
 	if res.Error != nil {
-		return res.Error
-	}
-	if res.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound
+		return false, res.Error
 	}
-	return nil
+	return res.RowsAffected == 1, nil
 }
 
-// ListByUserID returns payments joined with booking.bookings filtered by user ID
-func (r *paymentRepository) ListByUserID(ctx context.Context, userID string) ([]entity.Payment, error) {
+func (r *paymentRepository) ListByBookingID(ctx context.Context, bookingID string) ([]entity.Payment, error) {
 	var res []entity.Payment
-	// Use explicit schema qualification to avoid search_path issues
-	q := `SELECT p.*
-		  FROM "payment"."payments" p
-		  JOIN "booking"."bookings" b ON b.id::text = p.booking_id
-		  WHERE b.user_id::text = ?
-		  ORDER BY p.created_at DESC`
-	if err := r.db.WithContext(ctx).Raw(q, userID).Scan(&res).Error; err != nil {
+	if err := r.db.WithContext(ctx).
+		Where("booking_id = ?", bookingID).
+		Order("created_at DESC").
+		Find(&res).Error; err != nil {
 		return nil, err
 	}
 	return res, nil
 }
 
-// GetBookingTotal returns the total price for a booking from the booking schema
-func (r *paymentRepository) GetBookingTotal(ctx context.Context, bookingID string) (int64, error) {
-	var total int64
-	q := `SELECT b.total FROM "booking"."bookings" b WHERE b.id::text = ? LIMIT 1`
-	if err := r.db.WithContext(ctx).Raw(q, bookingID).Scan(&total).Error; err != nil {
-		return 0, err
+func (r *paymentRepository) ListByBookingIDs(ctx context.Context, bookingIDs []string) ([]entity.Payment, error) {
+	var res []entity.Payment
+	if len(bookingIDs) == 0 {
+		return res, nil
+	}
+	if err := r.db.WithContext(ctx).
+		Where("booking_id IN ?", bookingIDs).
+		Order("created_at DESC").
+		Find(&res).Error; err != nil {
+		return nil, err
 	}
-	return total, nil
+	return res, nil
 }
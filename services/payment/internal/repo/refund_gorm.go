@@ -5,6 +5,7 @@ import (
 	"payment/internal/entity"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type refundRepository struct {
@@ -18,3 +19,40 @@ func NewRefundRepository(db *gorm.DB) *refundRepository {
 func (r *refundRepository) Create(ctx context.Context, rf *entity.Refund) error {
 	return r.db.WithContext(ctx).Create(rf).Error
 }
+
+func (r *refundRepository) Record(ctx context.Context, rf *entity.Refund) (*entity.Payment, error) {
+	var p entity.Payment
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// lock the payment so concurrent refunds cannot overdraw its balance
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&p, "id = ?", rf.PaymentID).Error; err != nil {
+			return err
+		}
+		if err := p.ApplyRefund(rf.Amount); err != nil {
+			return err
+		}
+		if err := tx.Create(rf).Error; err != nil {
+			return err
+		}
+		return tx.Model(&entity.Payment{}).
+			Where("id = ?", p.ID).
+			Updates(map[string]any{
+				"refunded_amount": p.RefundedAmount,
+				"status":          p.Status,
+			}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *refundRepository) ListByPaymentID(ctx context.Context, paymentID string) ([]entity.Refund, error) {
+	var res []entity.Refund
+	if err := r.db.WithContext(ctx).
+		Where("payment_id = ?", paymentID).
+		Order("created_at ASC").
+		Find(&res).Error; err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidSignature    = errors.New("invalid webhook signature")
+	ErrGrossAmountMismatch = errors.New("gross_amount does not match payment amount")
+	ErrMissingOrderID      = errors.New("missing order_id")
+)
+
+// MidtransSignature computes the Midtrans notification signature:
+// hex(SHA512(order_id + status_code + gross_amount + server_key)).
+func MidtransSignature(orderID, statusCode, grossAmount, serverKey string) string {
+	sum := sha512.Sum512([]byte(orderID + statusCode + grossAmount + serverKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// SignMidtransPayload fills SignatureKey so that the payload passes webhook verification.
+// It is meant for tests and local webhook simulation.
+func SignMidtransPayload(p *MidtransWebhookPayload, serverKey string) {
+	p.SignatureKey = MidtransSignature(p.OrderID, p.StatusCode, p.GrossAmount, serverKey)
+}
+
+func verifyMidtransSignature(p MidtransWebhookPayload, serverKey string) bool {
+	if serverKey == "" || p.SignatureKey == "" {
+		return false
+	}
+	want := MidtransSignature(p.OrderID, p.StatusCode, p.GrossAmount, serverKey)
+	return subtle.ConstantTimeCompare([]byte(want), []byte(strings.ToLower(p.SignatureKey))) == 1
+}
+
+// parseGrossAmount converts Midtrans gross_amount ("150000.00") to whole rupiah.
+// Non-zero fractional parts are rejected since payments are stored as integers.
+func parseGrossAmount(s string) (int64, bool) {
+	whole, frac, _ := strings.Cut(strings.TrimSpace(s), ".")
+	if strings.Trim(frac, "0") != "" {
+		return 0, false
+	}
+	v, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
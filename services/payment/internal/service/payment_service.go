@@ -5,58 +5,179 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"payment/internal/entity"
+
+	"gorm.io/gorm"
 )
 
 type Service struct {
-	payRepo entity.PaymentRepo
-	refRepo entity.RefundRepo
-	book    entity.BookingClient
+	payRepo   entity.PaymentRepo
+	refRepo   entity.RefundRepo
+	book      entity.BookingClient
+	serverKey string
 }
 
-func NewPaymentService(p entity.PaymentRepo, r entity.RefundRepo, b entity.BookingClient) *Service {
-	return &Service{payRepo: p, refRepo: r, book: b}
+func NewPaymentService(p entity.PaymentRepo, r entity.RefundRepo, b entity.BookingClient, serverKey string) *Service {
+	return &Service{payRepo: p, refRepo: r, book: b, serverKey: serverKey}
 }
 
 type CreatePaymentResponse struct {
+	PaymentID   string `json:"payment_id"`
+	OrderID     string `json:"order_id"`
+	Attempt     int    `json:"attempt"`
 	SnapToken   string `json:"snap_token"`
 	RedirectURL string `json:"redirect_url"`
 	Amount      int64  `json:"amount"`
 }
 
-var ErrAmountMismatch = errors.New("amount does not match booking total")
+// MaxIdempotencyKeyLength bounds the Idempotency-Key header.
+const MaxIdempotencyKeyLength = 255
 
-func (s *Service) CreatePayment(ctx context.Context, bookingID string, amount int64) (*entity.Payment, *CreatePaymentResponse, error) {
-	// Validate amount equals booking total
-	total, err := s.payRepo.GetBookingTotal(ctx, bookingID)
+var (
+	ErrAmountMismatch = errors.New("amount does not match booking total")
+	// ErrNotBookingOwner is returned when the payer does not own the booking.
+	ErrNotBookingOwner = errors.New("booking does not belong to the caller")
+	// ErrBookingNotPayable is returned when the booking is no longer awaiting payment.
+	ErrBookingNotPayable = errors.New("booking is not awaiting payment")
+	// ErrBookingNotRefundable is returned when an owner refunds a booking that is not PAID;
+	// checked-in stays and bookings already being cancelled go through staff or Booking.
+	ErrBookingNotRefundable = errors.New("booking is not in a refundable status")
+	// ErrInvalidIdempotencyKey is returned for an over-long Idempotency-Key.
+	ErrInvalidIdempotencyKey = errors.New("idempotency key must be at most 255 characters")
+	// ErrIdempotencyKeyReused is returned when a key is replayed with a different amount.
+	ErrIdempotencyKeyReused = errors.New("idempotency key was already used with a different amount")
+)
+
+// Payer identifies who opens a payment: an account holder, or a guest whose token
+// is scoped to a single booking.
+type Payer struct {
+	UserID    string
+	BookingID string // guest tokens only
+}
+
+// owns reports whether the payer may pay the booking.
+func (p Payer) owns(b *entity.BookingInfo) bool {
+	if p.UserID != "" {
+		return b.UserID == p.UserID
+	}
+	return p.BookingID != "" && b.UserID == "" && p.BookingID == b.ID
+}
+
+// CreatePayment opens a payment attempt for a booking. A request repeating an
+// idempotency key gets the attempt created for that key back, whatever its status;
+// any other request while an attempt is PENDING gets that attempt. Otherwise a new
+// attempt is created once the previous ones expired or were denied.
+func (s *Service) CreatePayment(ctx context.Context, bookingID string, amount int64, payer Payer, idempotencyKey string) (*entity.Payment, *CreatePaymentResponse, error) {
+	if len(idempotencyKey) > MaxIdempotencyKeyLength {
+		return nil, nil, ErrInvalidIdempotencyKey
+	}
+	booking, err := s.book.GetBooking(ctx, bookingID)
 	if err != nil {
 		return nil, nil, err
 	}
-	if amount != total {
+	if !payer.owns(booking) {
+		return nil, nil, ErrNotBookingOwner
+	}
+
+	if idempotencyKey != "" {
+		p, err := s.payRepo.FindByIdempotencyKey(ctx, bookingID, idempotencyKey)
+		if err == nil {
+			if p.Amount != amount {
+				return nil, nil, ErrIdempotencyKeyReused
+			}
+			return p, paymentResponse(p), nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, err
+		}
+	}
+
+	if booking.Status != entity.BookingUnpaid {
+		return nil, nil, ErrBookingNotPayable
+	}
+	// Validate amount equals booking total
+	if amount != booking.Total {
 		return nil, nil, ErrAmountMismatch
 	}
-	orderID := fmt.Sprintf("BO-%s", bookingID)
+	return s.openAttempt(ctx, bookingID, amount, idempotencyKey)
+}
+
+// CreateIntent opens a payment attempt on behalf of the booking service, which
+// already knows the booking and its total. A PENDING attempt is reused.
+func (s *Service) CreateIntent(ctx context.Context, bookingID string, amount int64) (*entity.Payment, *CreatePaymentResponse, error) {
+	if bookingID == "" {
+		return nil, nil, errors.New("missing booking id")
+	}
+	return s.openAttempt(ctx, bookingID, amount, "")
+}
+
+// openAttempt returns the booking's PENDING attempt or creates the next one.
+func (s *Service) openAttempt(ctx context.Context, bookingID string, amount int64, idempotencyKey string) (*entity.Payment, *CreatePaymentResponse, error) {
+	attempts, err := s.payRepo.ListByBookingID(ctx, bookingID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if p := pendingAttempt(attempts); p != nil {
+		return p, paymentResponse(p), nil
+	}
+
+	attempt := len(attempts) + 1
 	p := &entity.Payment{
 		BookingID: bookingID,
-		OrderID:   orderID,
+		OrderID:   orderID(bookingID, attempt),
+		Attempt:   attempt,
 		Amount:    amount,
 		Provider:  "midtrans",
 		Status:    entity.PayPending,
 	}
+	if idempotencyKey != "" {
+		p.IdempotencyKey = &idempotencyKey
+	}
 	if err := s.payRepo.Create(ctx, p); err != nil {
+		// A concurrent request may have created the attempt first; hand that one out.
+		if attempts, lerr := s.payRepo.ListByBookingID(ctx, bookingID); lerr == nil {
+			if pending := pendingAttempt(attempts); pending != nil {
+				return pending, paymentResponse(pending), nil
+			}
+		}
 		return nil, nil, err
 	}
-	resp := &CreatePaymentResponse{
-		SnapToken:   "mock-" + orderID,
-		RedirectURL: "https://mock.midtrans/redirect/" + orderID,
-		Amount:      amount,
+	return p, paymentResponse(p), nil
+}
+
+// orderID keeps BO-{bookingID} for the first attempt and suffixes later ones.
+func orderID(bookingID string, attempt int) string {
+	if attempt <= 1 {
+		return fmt.Sprintf("BO-%s", bookingID)
+	}
+	return fmt.Sprintf("BO-%s-%d", bookingID, attempt)
+}
+
+func pendingAttempt(attempts []entity.Payment) *entity.Payment {
+	for i := range attempts {
+		if attempts[i].Status == entity.PayPending {
+			return &attempts[i]
+		}
+	}
+	return nil
+}
+
+func paymentResponse(p *entity.Payment) *CreatePaymentResponse {
+	return &CreatePaymentResponse{
+		PaymentID:   p.ID,
+		OrderID:     p.OrderID,
+		Attempt:     p.Attempt,
+		SnapToken:   "mock-" + p.OrderID,
+		RedirectURL: "https://mock.midtrans/redirect/" + p.OrderID,
+		Amount:      p.Amount,
 	}
-	return p, resp, nil
 }
 
 type MidtransWebhookPayload struct {
 	OrderID           string `json:"order_id"`
 	TransactionStatus string `json:"transaction_status"`
+	StatusCode        string `json:"status_code"`
 	GrossAmount       string `json:"gross_amount"`
 	TransactionID     string `json:"transaction_id"`
 	SignatureKey      string `json:"signature_key"`
@@ -64,49 +185,287 @@ type MidtransWebhookPayload struct {
 
 func (s *Service) HandleMidtransWebhook(ctx context.Context, payload MidtransWebhookPayload) error {
 	if payload.OrderID == "" {
-		return errors.New("missing order_id")
+		return ErrMissingOrderID
+	}
+	if !verifyMidtransSignature(payload, s.serverKey) {
+		log.Printf("security: rejected midtrans webhook order_id=%s status=%s: bad signature", payload.OrderID, payload.TransactionStatus)
+		return ErrInvalidSignature
 	}
 	pay, err := s.payRepo.FindByOrderID(ctx, payload.OrderID)
 	if err != nil {
 		return err
 	}
+	if gross, ok := parseGrossAmount(payload.GrossAmount); !ok || gross != pay.Amount {
+		log.Printf("security: rejected midtrans webhook order_id=%s: gross_amount %q != payment amount %d", payload.OrderID, payload.GrossAmount, pay.Amount)
+		return ErrGrossAmountMismatch
+	}
 	rawBytes, _ := json.Marshal(payload)
+	// Notifications can be redelivered, replayed or arrive out of order, so each only
+	// moves the attempt out of the states it can follow; one already applied is a no-op.
 	switch payload.TransactionStatus {
 	case "settlement":
-		if err := s.payRepo.UpdateStatus(ctx, pay.ID, entity.PaySettlement, string(rawBytes), payload.TransactionID); err != nil {
+		// an attempt that expired on our side may still be captured late
+		changed, err := s.payRepo.UpdateStatusFrom(ctx, pay.ID, []entity.PaymentStatus{entity.PayPending, entity.PayExpire}, entity.PaySettlement, string(rawBytes), payload.TransactionID)
+		if err != nil {
 			return err
 		}
-		return s.book.UpdateStatusPaid(ctx, pay.BookingID)
-	case "expire", "cancel", "deny":
-		if err := s.payRepo.UpdateStatus(ctx, pay.ID, entity.PayExpire, string(rawBytes), payload.TransactionID); err != nil {
-			return err
+		if !changed {
+			if pay, err = s.payRepo.FindByID(ctx, pay.ID); err != nil {
+				return err
+			}
+			if pay.Status != entity.PaySettlement {
+				// already (partially) refunded; the settlement is old news
+				return nil
+			}
 		}
-		return s.book.UpdateStatusExpired(ctx, pay.BookingID)
+		// also on a redelivery, in case marking the booking failed the first time
+		return s.settleBooking(ctx, pay)
+	case "expire", "cancel", "deny":
+		// Only the attempt ends; the booking stays UNPAID so the guest can retry until
+		// the booking's own payment deadline cancels it. A captured payment is kept.
+		_, err := s.payRepo.UpdateStatusFrom(ctx, pay.ID, []entity.PaymentStatus{entity.PayPending}, entity.PayExpire, string(rawBytes), payload.TransactionID)
+		return err
 	default:
 		// ignore other statuses for mock
 		return nil
 	}
 }
 
-func (s *Service) Refund(ctx context.Context, paymentID string, amount int64) error {
+// settleBooking marks the booking of a captured payment PAID. Booking refuses when it
+// is paid already, which is fine for a redelivered settlement; but money captured for
+// a booking that was cancelled (or deleted) meanwhile, or that another attempt already
+// paid, is refunded so it is not kept for nothing.
+func (s *Service) settleBooking(ctx context.Context, pay *entity.Payment) error {
+	err := s.book.UpdateStatusPaid(ctx, pay.BookingID)
+	switch {
+	case errors.Is(err, entity.ErrBookingNotFound):
+		// deleted meanwhile
+	case errors.Is(err, entity.ErrBookingStatusConflict):
+		booking, err := s.book.GetBooking(ctx, pay.BookingID)
+		if err != nil && !errors.Is(err, entity.ErrBookingNotFound) {
+			return err
+		}
+		if booking != nil && booking.Status != entity.BookingCancelled {
+			// paid already: a redelivery, unless another attempt paid it
+			paidByOther, err := s.paidByOtherAttempt(ctx, pay)
+			if err != nil || !paidByOther {
+				return err
+			}
+		}
+	default:
+		return err
+	}
+	s.refundUnclaimed(ctx, pay)
+	return nil
+}
+
+// paidByOtherAttempt reports whether another attempt of the payment's booking was captured.
+func (s *Service) paidByOtherAttempt(ctx context.Context, pay *entity.Payment) (bool, error) {
+	attempts, err := s.payRepo.ListByBookingID(ctx, pay.BookingID)
+	if err != nil {
+		return false, err
+	}
+	for _, p := range attempts {
+		if p.ID != pay.ID && (p.Status == entity.PaySettlement || p.Status == entity.PayPartiallyRefunded || p.Status == entity.PayRefunded) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// refundUnclaimed refunds a captured payment its booking cannot take. The notification
+// is still acknowledged, so a failure is logged for staff to refund by hand.
+func (s *Service) refundUnclaimed(ctx context.Context, pay *entity.Payment) {
+	reference := "unclaimed:" + pay.ID
+	rf := &entity.Refund{
+		PaymentID: pay.ID,
+		Reference: &reference,
+		Amount:    pay.RefundableAmount(),
+		Reason:    "payment captured for a cancelled or already paid booking",
+		Status:    "SUCCESS",
+	}
+	if _, err := s.refRepo.Record(ctx, rf); err != nil {
+		log.Printf("MANUAL REVIEW: payment_id=%s order_id=%s captured %d for booking %s that cannot take it; refund failed: %v", pay.ID, pay.OrderID, pay.Amount, pay.BookingID, err)
+		return
+	}
+	log.Printf("refunded payment_id=%s order_id=%s: captured for booking %s that cannot take it", pay.ID, pay.OrderID, pay.BookingID)
+}
+
+// RefundResult describes a recorded refund and the payment balance after it.
+type RefundResult struct {
+	PaymentID        string               `json:"payment_id"`
+	Status           entity.PaymentStatus `json:"status"`
+	RefundID         string               `json:"refund_id"`
+	Amount           int64                `json:"amount"`
+	Reason           string               `json:"reason"`
+	RefundedAmount   int64                `json:"refunded_amount"`
+	RefundableAmount int64                `json:"refundable_amount"`
+}
+
+// DefaultRefundReason is recorded when a refund is requested without a reason.
+const DefaultRefundReason = "requested refund"
+
+// Refunder identifies who asks for a refund. Manage is set for STAFF/ADMIN, who may
+// refund any payment; everyone else only payments of their own bookings.
+type Refunder struct {
+	UserID string
+	Manage bool
+}
+
+// Refund refunds part or, with amount 0, all of the payment's remaining balance.
+// The booking is marked REFUNDED only once the payment is fully refunded.
+func (s *Service) Refund(ctx context.Context, paymentID string, amount int64, reason string, by Refunder) (*RefundResult, error) {
 	if paymentID == "" {
-		return errors.New("missing payment id")
+		return nil, errors.New("missing payment id")
 	}
-	// Persist refund
-	rf := &entity.Refund{PaymentID: paymentID, Amount: amount, Status: "SUCCESS"}
-	if err := s.refRepo.Create(ctx, rf); err != nil {
-		return err
+	if amount < 0 {
+		return nil, entity.ErrRefundExceedsBalance
+	}
+	p, err := s.payRepo.FindByID(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if !by.Manage {
+		booking, err := s.book.GetBooking(ctx, p.BookingID)
+		if err != nil && !errors.Is(err, entity.ErrBookingNotFound) {
+			return nil, err
+		}
+		if booking == nil || by.UserID == "" || booking.UserID != by.UserID {
+			return nil, ErrNotBookingOwner
+		}
+		if booking.Status != entity.BookingPaid {
+			return nil, ErrBookingNotRefundable
+		}
+	}
+	if amount == 0 {
+		if amount = p.RefundableAmount(); amount == 0 {
+			return nil, entity.ErrPaymentNotRefundable
+		}
+	}
+	if reason == "" {
+		reason = DefaultRefundReason
+	}
+
+	rf := &entity.Refund{PaymentID: paymentID, Amount: amount, Reason: reason, Status: "SUCCESS"}
+	p, err = s.refRepo.Record(ctx, rf)
+	if err != nil {
+		return nil, err
+	}
+	if p.Status == entity.PayRefunded {
+		if err := s.book.UpdateStatusRefunded(ctx, p.BookingID); err != nil {
+			// the money is already refunded; the booking status can be fixed up by staff
+			log.Printf("refund payment_id=%s: mark booking %s refunded: %v", p.ID, p.BookingID, err)
+		}
+	}
+
+	return &RefundResult{
+		PaymentID:        p.ID,
+		Status:           p.Status,
+		RefundID:         rf.ID,
+		Amount:           rf.Amount,
+		Reason:           rf.Reason,
+		RefundedAmount:   p.RefundedAmount,
+		RefundableAmount: p.RefundableAmount(),
+	}, nil
+}
+
+// RefundBooking refunds up to amount from the booking's settled payments on behalf of
+// the booking service, which updates the booking itself, so it is not notified back.
+// reference identifies the cancellation: refunds already recorded under it count
+// towards amount, so a retry after a lost response refunds nothing twice. It returns
+// the amount refunded under reference, and succeeds when the booking's payments were
+// already refunded in full by other means.
+func (s *Service) RefundBooking(ctx context.Context, bookingID string, amount int64, reason, reference string) (int64, error) {
+	if bookingID == "" || reference == "" {
+		return 0, errors.New("missing booking id or refund reference")
 	}
-	// Update payment and notify booking
-	if err := s.payRepo.UpdateStatus(ctx, paymentID, entity.PayRefunded, "{}", ""); err != nil {
+	if amount <= 0 {
+		return 0, entity.ErrRefundExceedsBalance
+	}
+	if reason == "" {
+		reason = DefaultRefundReason
+	}
+	list, err := s.payRepo.ListByBookingID(ctx, bookingID)
+	if err != nil {
+		return 0, err
+	}
+
+	var refunded int64
+	var pending []entity.Payment
+	for _, p := range list {
+		done, err := s.refundedUnder(ctx, p.ID, reference)
+		if err != nil {
+			return 0, err
+		}
+		if done > 0 {
+			refunded += done
+			continue
+		}
+		pending = append(pending, p)
+	}
+
+	var refundedElsewhere bool
+	for _, p := range pending {
+		refundedElsewhere = refundedElsewhere || p.RefundedAmount > 0
+		left := min(p.RefundableAmount(), amount-refunded)
+		if left <= 0 {
+			continue
+		}
+		rf := &entity.Refund{PaymentID: p.ID, Reference: &reference, Amount: left, Reason: reason, Status: "SUCCESS"}
+		if _, err := s.refRepo.Record(ctx, rf); err != nil {
+			return refunded, err
+		}
+		refunded += left
+	}
+	if refunded == 0 && !refundedElsewhere {
+		return 0, entity.ErrPaymentNotRefundable
+	}
+	return refunded, nil
+}
+
+// refundedUnder sums the refunds of a payment recorded under reference.
+func (s *Service) refundedUnder(ctx context.Context, paymentID, reference string) (int64, error) {
+	refunds, err := s.refRepo.ListByPaymentID(ctx, paymentID)
+	if err != nil {
+		return 0, err
+	}
+	var sum int64
+	for _, rf := range refunds {
+		if rf.Reference != nil && *rf.Reference == reference {
+			sum += rf.Amount
+		}
+	}
+	return sum, nil
+}
+
+// ExpireByBooking marks pending payments of a booking as expired. It is called by the
+// booking service after it cancelled an overdue booking, so booking is not notified back.
+func (s *Service) ExpireByBooking(ctx context.Context, bookingID string) error {
+	if bookingID == "" {
+		return errors.New("missing booking id")
+	}
+	list, err := s.payRepo.ListByBookingID(ctx, bookingID)
+	if err != nil {
 		return err
 	}
-	// We need bookingID; in this simplified mock, caller should already know and webhook would have updated
-	// Since UpdateStatus uses paymentID only, we cannot infer bookingID here without extra fetch; skip notify if unknown
+	for _, p := range list {
+		if p.Status != entity.PayPending {
+			continue
+		}
+		// a settlement arriving meanwhile wins; HandleMidtransWebhook deals with it
+		if _, err := s.payRepo.UpdateStatusFrom(ctx, p.ID, []entity.PaymentStatus{entity.PayPending}, entity.PayExpire, p.RawPayload, p.ProviderRef); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// ListByUserID returns all payments for bookings owned by the given user ID.
+// ListByUserID returns all payments for bookings owned by the given user ID. Bookings
+// belong to the booking service, so their IDs are asked from it rather than read from its schema.
 func (s *Service) ListByUserID(ctx context.Context, userID string) ([]entity.Payment, error) {
-	return s.payRepo.ListByUserID(ctx, userID)
+	bookingIDs, err := s.book.ListUserBookingIDs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.payRepo.ListByBookingIDs(ctx, bookingIDs)
 }
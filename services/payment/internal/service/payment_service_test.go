@@ -0,0 +1,353 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"payment/internal/entity"
+
+	"gorm.io/gorm"
+)
+
+// memPayments is an in-memory entity.PaymentRepo.
+type memPayments struct {
+	byID map[string]*entity.Payment
+}
+
+func newMemPayments(list ...entity.Payment) *memPayments {
+	m := &memPayments{byID: make(map[string]*entity.Payment)}
+	for i := range list {
+		p := list[i]
+		m.byID[p.ID] = &p
+	}
+	return m
+}
+
+func (m *memPayments) Create(_ context.Context, p *entity.Payment) error {
+	m.byID[p.ID] = p
+	return nil
+}
+
+func (m *memPayments) FindByID(_ context.Context, id string) (*entity.Payment, error) {
+	if p, ok := m.byID[id]; ok {
+		cp := *p
+		return &cp, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *memPayments) FindByOrderID(_ context.Context, orderID string) (*entity.Payment, error) {
+	for _, p := range m.byID {
+		if p.OrderID == orderID {
+			cp := *p
+			return &cp, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *memPayments) FindByIdempotencyKey(_ context.Context, bookingID, key string) (*entity.Payment, error) {
+	for _, p := range m.byID {
+		if p.BookingID == bookingID && p.IdempotencyKey != nil && *p.IdempotencyKey == key {
+			cp := *p
+			return &cp, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *memPayments) UpdateStatusFrom(_ context.Context, id string, from []entity.PaymentStatus, status entity.PaymentStatus, raw, providerRef string) (bool, error) {
+	p, ok := m.byID[id]
+	if !ok || !slices.Contains(from, p.Status) {
+		return false, nil
+	}
+	p.Status, p.RawPayload, p.ProviderRef = status, raw, providerRef
+	return true, nil
+}
+
+func (m *memPayments) ListByBookingID(_ context.Context, bookingID string) ([]entity.Payment, error) {
+	var res []entity.Payment
+	for _, p := range m.byID {
+		if p.BookingID == bookingID {
+			res = append(res, *p)
+		}
+	}
+	return res, nil
+}
+
+func (m *memPayments) ListByBookingIDs(ctx context.Context, bookingIDs []string) ([]entity.Payment, error) {
+	var res []entity.Payment
+	for _, id := range bookingIDs {
+		list, _ := m.ListByBookingID(ctx, id)
+		res = append(res, list...)
+	}
+	return res, nil
+}
+
+// memRefunds is an in-memory entity.RefundRepo applying refunds to memPayments.
+type memRefunds struct {
+	payments *memPayments
+	list     []entity.Refund
+}
+
+func (m *memRefunds) Create(_ context.Context, r *entity.Refund) error {
+	m.list = append(m.list, *r)
+	return nil
+}
+
+func (m *memRefunds) Record(_ context.Context, r *entity.Refund) (*entity.Payment, error) {
+	p, ok := m.payments.byID[r.PaymentID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if err := p.ApplyRefund(r.Amount); err != nil {
+		return nil, err
+	}
+	m.list = append(m.list, *r)
+	cp := *p
+	return &cp, nil
+}
+
+func (m *memRefunds) ListByPaymentID(_ context.Context, paymentID string) ([]entity.Refund, error) {
+	var res []entity.Refund
+	for _, r := range m.list {
+		if r.PaymentID == paymentID {
+			res = append(res, r)
+		}
+	}
+	return res, nil
+}
+
+// fakeBooking records the status updates sent to the booking service. With status
+// set, it knows the booking and refuses to mark it paid unless it is UNPAID; deleted
+// makes it answer like for a booking that no longer exists.
+type fakeBooking struct {
+	status  string
+	deleted bool
+	paid    []string
+}
+
+func (f *fakeBooking) GetBooking(_ context.Context, bookingID string) (*entity.BookingInfo, error) {
+	if f.status == "" {
+		return nil, entity.ErrBookingNotFound
+	}
+	return &entity.BookingInfo{ID: bookingID, Status: f.status}, nil
+}
+
+func (f *fakeBooking) ListUserBookingIDs(context.Context, string) ([]string, error) {
+	return nil, nil
+}
+
+func (f *fakeBooking) UpdateStatusPaid(_ context.Context, bookingID string) error {
+	if f.deleted {
+		return entity.ErrBookingNotFound
+	}
+	if f.status != "" && f.status != entity.BookingUnpaid {
+		return entity.ErrBookingStatusConflict
+	}
+	f.paid = append(f.paid, bookingID)
+	return nil
+}
+
+func (f *fakeBooking) UpdateStatusExpired(context.Context, string) error  { return nil }
+func (f *fakeBooking) UpdateStatusRefunded(context.Context, string) error { return nil }
+
+const testServerKey = "SB-Mid-server-test"
+
+func TestHandleMidtransWebhook(t *testing.T) {
+	pending := entity.Payment{
+		ID:        "pay-1",
+		BookingID: "booking-1",
+		OrderID:   "BO-booking-1",
+		Amount:    150000,
+		Status:    entity.PayPending,
+	}
+
+	tests := []struct {
+		name       string
+		payload    func() MidtransWebhookPayload
+		wantErr    error
+		wantStatus entity.PaymentStatus
+		wantPaid   bool
+	}{
+		{
+			name: "valid signature settles the payment",
+			payload: func() MidtransWebhookPayload {
+				p := MidtransWebhookPayload{OrderID: "BO-booking-1", TransactionStatus: "settlement", StatusCode: "200", GrossAmount: "150000.00", TransactionID: "trx-1"}
+				SignMidtransPayload(&p, testServerKey)
+				return p
+			},
+			wantStatus: entity.PaySettlement,
+			wantPaid:   true,
+		},
+		{
+			name: "tampered signature is rejected",
+			payload: func() MidtransWebhookPayload {
+				p := MidtransWebhookPayload{OrderID: "BO-booking-1", TransactionStatus: "settlement", StatusCode: "200", GrossAmount: "150000.00"}
+				SignMidtransPayload(&p, testServerKey)
+				p.GrossAmount = "1.00"
+				return p
+			},
+			wantErr:    ErrInvalidSignature,
+			wantStatus: entity.PayPending,
+		},
+		{
+			name: "signature from another key is rejected",
+			payload: func() MidtransWebhookPayload {
+				p := MidtransWebhookPayload{OrderID: "BO-booking-1", TransactionStatus: "settlement", StatusCode: "200", GrossAmount: "150000.00"}
+				SignMidtransPayload(&p, "some-other-key")
+				return p
+			},
+			wantErr:    ErrInvalidSignature,
+			wantStatus: entity.PayPending,
+		},
+		{
+			name: "mismatched gross_amount is rejected",
+			payload: func() MidtransWebhookPayload {
+				p := MidtransWebhookPayload{OrderID: "BO-booking-1", TransactionStatus: "settlement", StatusCode: "200", GrossAmount: "1000.00"}
+				SignMidtransPayload(&p, testServerKey)
+				return p
+			},
+			wantErr:    ErrGrossAmountMismatch,
+			wantStatus: entity.PayPending,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payments := newMemPayments(pending)
+			booking := &fakeBooking{}
+			svc := NewPaymentService(payments, nil, booking, testServerKey)
+
+			err := svc.HandleMidtransWebhook(context.Background(), tt.payload())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("HandleMidtransWebhook() error = %v, want %v", err, tt.wantErr)
+			}
+			if got := payments.byID["pay-1"].Status; got != tt.wantStatus {
+				t.Errorf("payment status = %s, want %s", got, tt.wantStatus)
+			}
+			if paid := len(booking.paid) > 0; paid != tt.wantPaid {
+				t.Errorf("booking marked paid = %v, want %v", paid, tt.wantPaid)
+			}
+		})
+	}
+}
+
+func TestRefundBookingRetryIsIdempotent(t *testing.T) {
+	payments := newMemPayments(entity.Payment{ID: "pay-1", BookingID: "booking-1", Amount: 150000, Status: entity.PaySettlement})
+	refunds := &memRefunds{payments: payments}
+	svc := NewPaymentService(payments, refunds, &fakeBooking{}, testServerKey)
+	ctx := context.Background()
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		refunded, err := svc.RefundBooking(ctx, "booking-1", 150000, "", "cancel:booking-1")
+		if err != nil {
+			t.Fatalf("attempt %d: RefundBooking() error = %v", attempt, err)
+		}
+		if refunded != 150000 {
+			t.Errorf("attempt %d: refunded = %d, want 150000", attempt, refunded)
+		}
+	}
+	if len(refunds.list) != 1 {
+		t.Errorf("refunds = %d, want 1", len(refunds.list))
+	}
+	if p := payments.byID["pay-1"]; p.Status != entity.PayRefunded || p.RefundedAmount != 150000 {
+		t.Errorf("payment = %s with %d refunded, want REFUNDED with 150000", p.Status, p.RefundedAmount)
+	}
+}
+
+func TestRefundBookingSucceedsWhenAlreadyRefunded(t *testing.T) {
+	payments := newMemPayments(entity.Payment{ID: "pay-1", BookingID: "booking-1", Amount: 150000, RefundedAmount: 150000, Status: entity.PayRefunded})
+	svc := NewPaymentService(payments, &memRefunds{payments: payments}, &fakeBooking{}, testServerKey)
+
+	if _, err := svc.RefundBooking(context.Background(), "booking-1", 150000, "", "cancel:booking-1"); err != nil {
+		t.Fatalf("RefundBooking() error = %v, want nil", err)
+	}
+}
+
+func TestRefundBookingWithoutSettledPayment(t *testing.T) {
+	payments := newMemPayments(entity.Payment{ID: "pay-1", BookingID: "booking-1", Amount: 150000, Status: entity.PayExpire})
+	svc := NewPaymentService(payments, &memRefunds{payments: payments}, &fakeBooking{}, testServerKey)
+
+	_, err := svc.RefundBooking(context.Background(), "booking-1", 150000, "", "cancel:booking-1")
+	if !errors.Is(err, entity.ErrPaymentNotRefundable) {
+		t.Fatalf("RefundBooking() error = %v, want %v", err, entity.ErrPaymentNotRefundable)
+	}
+}
+
+func TestHandleMidtransWebhookTransitions(t *testing.T) {
+	tests := []struct {
+		name       string
+		from       entity.PaymentStatus
+		txStatus   string
+		wantStatus entity.PaymentStatus
+		wantPaid   bool
+	}{
+		{name: "late capture of an expired attempt", from: entity.PayExpire, txStatus: "settlement", wantStatus: entity.PaySettlement, wantPaid: true},
+		{name: "redelivered settlement re-marks the booking", from: entity.PaySettlement, txStatus: "settlement", wantStatus: entity.PaySettlement, wantPaid: true},
+		{name: "replayed settlement keeps a refund", from: entity.PayRefunded, txStatus: "settlement", wantStatus: entity.PayRefunded},
+		{name: "replayed settlement keeps a partial refund", from: entity.PayPartiallyRefunded, txStatus: "settlement", wantStatus: entity.PayPartiallyRefunded},
+		{name: "expiry of a pending attempt", from: entity.PayPending, txStatus: "expire", wantStatus: entity.PayExpire},
+		{name: "late expiry keeps a capture", from: entity.PaySettlement, txStatus: "expire", wantStatus: entity.PaySettlement},
+		{name: "late deny keeps a capture", from: entity.PaySettlement, txStatus: "deny", wantStatus: entity.PaySettlement},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payments := newMemPayments(entity.Payment{ID: "pay-1", BookingID: "booking-1", OrderID: "BO-booking-1", Amount: 150000, Status: tt.from})
+			booking := &fakeBooking{}
+			svc := NewPaymentService(payments, nil, booking, testServerKey)
+
+			p := MidtransWebhookPayload{OrderID: "BO-booking-1", TransactionStatus: tt.txStatus, StatusCode: "200", GrossAmount: "150000.00"}
+			SignMidtransPayload(&p, testServerKey)
+			if err := svc.HandleMidtransWebhook(context.Background(), p); err != nil {
+				t.Fatalf("HandleMidtransWebhook() error = %v", err)
+			}
+			if got := payments.byID["pay-1"].Status; got != tt.wantStatus {
+				t.Errorf("payment status = %s, want %s", got, tt.wantStatus)
+			}
+			if paid := len(booking.paid) > 0; paid != tt.wantPaid {
+				t.Errorf("booking marked paid = %v, want %v", paid, tt.wantPaid)
+			}
+		})
+	}
+}
+
+func TestHandleMidtransWebhookSettlementForUnpayableBooking(t *testing.T) {
+	settled := entity.Payment{ID: "pay-0", BookingID: "booking-1", OrderID: "BO-booking-1", Amount: 150000, Status: entity.PaySettlement}
+	tests := []struct {
+		name         string
+		bookingState string
+		others       []entity.Payment
+		wantStatus   entity.PaymentStatus
+	}{
+		{name: "redelivery for a booking it paid", bookingState: "PAID", wantStatus: entity.PaySettlement},
+		{name: "capture after the booking was cancelled", bookingState: entity.BookingCancelled, wantStatus: entity.PayRefunded},
+		{name: "capture after the booking was deleted", bookingState: "", wantStatus: entity.PayRefunded},
+		{name: "second capture of a paid booking", bookingState: "PAID", others: []entity.Payment{settled}, wantStatus: entity.PayRefunded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payments := newMemPayments(append(tt.others, entity.Payment{ID: "pay-1", BookingID: "booking-1", OrderID: "BO-booking-1-2", Attempt: 2, Amount: 150000, Status: entity.PaySettlement})...)
+			refunds := &memRefunds{payments: payments}
+			svc := NewPaymentService(payments, refunds, &fakeBooking{status: tt.bookingState, deleted: tt.bookingState == ""}, testServerKey)
+
+			p := MidtransWebhookPayload{OrderID: "BO-booking-1-2", TransactionStatus: "settlement", StatusCode: "200", GrossAmount: "150000.00"}
+			SignMidtransPayload(&p, testServerKey)
+			for delivery := 1; delivery <= 2; delivery++ {
+				if err := svc.HandleMidtransWebhook(context.Background(), p); err != nil {
+					t.Fatalf("delivery %d: HandleMidtransWebhook() error = %v", delivery, err)
+				}
+			}
+			if got := payments.byID["pay-1"].Status; got != tt.wantStatus {
+				t.Errorf("payment status = %s, want %s", got, tt.wantStatus)
+			}
+			if want := tt.wantStatus == entity.PayRefunded; (len(refunds.list) == 1) != want || len(refunds.list) > 1 {
+				t.Errorf("refunds = %d, want refunded %v exactly once", len(refunds.list), want)
+			}
+		})
+	}
+}